@@ -1,24 +1,88 @@
 package main
 
 import (
+	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 )
 
-func filterOut(info fs.FileInfo, cfg config) bool {
-	// if the extension filter doesn't start with a dot, then add one
-	if len(cfg.ext) > 0 && cfg.ext[0] != '.' {
-		cfg.ext = "." + cfg.ext
-	}
+// prefix of the permissions recorded in the comment of the archives
+const archiveModePrefix = "mode="
+
+// prefixes of the lines written by the action loggers
+const (
+	delLogPrefix   = "DELETED FILE: "
+	archLogPrefix  = "ARCHIVED FILE: "
+	trashLogPrefix = "TRASHED FILE: "
+	purgeLogPrefix = "PURGED FILE: "
+	linkLogPrefix  = "LINKED FILE: "
+	execLogPrefix  = "EXECUTED: "
+	moveLogPrefix  = "MOVED FILE: "
+	chmodLogPrefix = "CHMOD FILE: "
+	chownLogPrefix = "CHOWN FILE: "
+	syncLogPrefix  = "SYNCED FILE: "
+	pruneLogPrefix = "PRUNED FILE: "
+	dupeLogPrefix  = "DELETED DUPE: "
+	dryRunPrefix   = "DRY RUN: "
+)
+
+// how symbolic links are handled
+const (
+	symlinksFile   = "file"
+	symlinksSkip   = "skip"
+	symlinksFollow = "follow"
+	symlinksOnly   = "only"
+)
+
+// file types accepted by -type
+const (
+	fileTypeFile   = 'f'
+	fileTypeDir    = 'd'
+	fileTypeLink   = 'l'
+	fileTypePipe   = 'p'
+	fileTypeSocket = 's'
+)
 
+// actions reported for the matched files
+const (
+	actionNone    = "none"
+	actionList    = "list"
+	actionArchive = "archive"
+	actionTrash   = "trash"
+	actionDelete  = "delete"
+	actionExec    = "exec"
+	actionMove    = "move"
+	actionChmod   = "chmod"
+	actionChown   = "chown"
+	actionSync    = "sync"
+)
+
+func filterOut(path string, info fs.FileInfo, cfg config) bool {
 	switch {
-	case info.IsDir():
-	case len(cfg.ext) > 0 && filepath.Ext(info.Name()) != cfg.ext:
+	case info.IsDir() && !strings.ContainsRune(cfg.types, fileTypeDir):
+	case cfg.types != "" && !matchType(info, cfg.types):
+	case cfg.symlinks == symlinksSkip && isLink(info):
+	case cfg.symlinks == symlinksOnly && !isLink(info):
+	case len(cfg.ext) > 0 && !matchExt(info.Name(), cfg.ext):
 	case info.Size() < int64(cfg.minSize):
+	case cfg.maxSize > 0 && info.Size() > int64(cfg.maxSize):
+	case !cfg.olderThan.IsZero() && !info.ModTime().Before(cfg.olderThan):
+	case !cfg.newerThan.IsZero() && !info.ModTime().After(cfg.newerThan):
+	case len(cfg.include) > 0 && !matchAny(cfg.include, cfg.root, path, info):
+	case matchAny(cfg.exclude, cfg.root, path, info):
+	case cfg.match != nil && !cfg.match.MatchString(path):
+	case cfg.minDepth > 0 && pathDepth(cfg.root, path) < cfg.minDepth:
+	case cfg.executable && info.Mode()&0111 == 0:
+	case cfg.perm.op != 0 && !cfg.perm.match(info.Mode()):
+	case !matchOwner(info, cfg):
+	case cfg.kept[path]:
 	default:
 		return false
 	}
@@ -26,16 +90,307 @@ func filterOut(info fs.FileInfo, cfg config) bool {
 	return true
 }
 
+// skipDir reports whether the directory at 'path' is excluded, in which case
+// none of its contents should be visited. The archive, trash, move and sync
+// directories are always skipped so that the files copied there aren't
+// matched again.
+func skipDir(path string, info fs.FileInfo, cfg config) bool {
+	return info.IsDir() && path != cfg.root &&
+		(matchAny(cfg.exclude, cfg.root, path, info) || cfg.outputDirs[path])
+}
+
+// outputDirs returns the archive, trash, move and sync directories under
+// the root, in the form of the walked paths, so that they're recognized
+// whether they and the root are given as relative or absolute paths.
+func outputDirs(cfg config) map[string]bool {
+	dirs := map[string]bool{}
+
+	root, err := filepath.Abs(cfg.root)
+	if err != nil {
+		return dirs
+	}
+
+	for _, dir := range []string{cfg.archive, cfg.trashDir, cfg.move,
+		cfg.sync} {
+		if dir == "" {
+			continue
+		}
+
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, abs)
+		if err != nil || rel == ".." ||
+			strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		dirs[filepath.Join(cfg.root, rel)] = true
+	}
+
+	return dirs
+}
+
+// matchType reports whether the file is of one of the types, given by their
+// letters as in find's -type
+func matchType(info fs.FileInfo, types string) bool {
+	mode := info.Mode()
+
+	for _, t := range types {
+		switch {
+		case t == fileTypeFile && mode.IsRegular():
+		case t == fileTypeDir && mode.IsDir():
+		case t == fileTypeLink && mode&fs.ModeSymlink != 0:
+		case t == fileTypePipe && mode&fs.ModeNamedPipe != 0:
+		case t == fileTypeSocket && mode&fs.ModeSocket != 0:
+		default:
+			continue
+		}
+		return true
+	}
+
+	return false
+}
+
+// pathDepth returns how many levels below 'root' the path is. Files directly
+// under 'root' have a depth of 1.
+func pathDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+
+	return strings.Count(filepath.ToSlash(rel), "/") + 1
+}
+
+// matchExt reports whether the file name has one of the extensions
+func matchExt(name string, exts []string) bool {
+	fileExt := filepath.Ext(name)
+
+	for _, ext := range exts {
+		// if the extension filter doesn't start with a dot, then add one
+		if len(ext) > 0 && ext[0] != '.' {
+			ext = "." + ext
+		}
+
+		if fileExt == ext {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAny reports whether any of the glob patterns match the file. Patterns
+// containing a separator are matched against the path relative to 'root',
+// the others against the file name only. A trailing separator restricts the
+// pattern to directories.
+func matchAny(patterns []string, root, path string, info fs.FileInfo) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/") {
+			if !info.IsDir() {
+				continue
+			}
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+
+		target := info.Name()
+		if strings.Contains(pattern, "/") {
+			target = rel
+		}
+
+		// patterns are validated when parsed, so errors can't happen here
+		if ok, _ := filepath.Match(pattern, target); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
 func listFile(path string, out io.Writer) error {
 	_, err := fmt.Fprintln(out, path)
 	return err
 }
 
-func deleteFile(path string, delLogger *log.Logger) error {
-	if err := os.Remove(path); err != nil {
+func deleteFile(path string, delLogger *log.Logger, dryRun bool) error {
+	if !dryRun {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	// absolute paths let the files be restored from anywhere
+	delLogger.Println(absPath(path))
+	return nil
+}
+
+// absPath returns the absolute form of 'path', or 'path' itself if it can't
+// be determined
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	return abs
+}
+
+// archiveFile gzip-compresses the file at 'path' into 'destDir', mirroring its
+// location relative to 'root', and returns the path of the archive. Earlier
+// archives of the same path are never overwritten, the new one gets a
+// numbered suffix instead. The archive is only considered complete once both
+// the gzip stream and the destination file have been closed successfully.
+// Only regular files can be archived, reading a named pipe would block.
+func archiveFile(destDir, root, path string, info fs.FileInfo,
+	dryRun bool) (string, error) {
+	if !info.Mode().IsRegular() {
+		return "", ErrNotRegular.Errorf(path)
+	}
+
+	targetPath, err := archivedCopy(destDir, root, path)
+	if err != nil {
+		return "", err
+	}
+
+	if targetPath, err = suffixPath(targetPath); err != nil {
+		return "", err
+	}
+
+	if dryRun {
+		return targetPath, nil
+	}
+
+	return targetPath, writeArchive(targetPath, path)
+}
+
+// archivedCopy returns the path of the archive of 'path' in 'destDir'
+func archivedCopy(destDir, root, path string) (string, error) {
+	relDir, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+
+	dest := fmt.Sprintf("%s.gz", filepath.Base(path))
+	return filepath.Join(destDir, relDir, dest), nil
+}
+
+// writeArchive writes the gzip-compressed contents of 'path' to
+// 'targetPath'. The archive is written next to its destination and renamed
+// into place once complete, so it's never left half written.
+func writeArchive(targetPath, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(targetPath),
+		fmt.Sprintf(".%s.walk-archive", filepath.Base(targetPath)))
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := compressFile(out, in, info); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, targetPath); err != nil {
+		os.Remove(tmp)
 		return err
 	}
 
-	delLogger.Println(path)
 	return nil
 }
+
+// compressFile writes the gzip-compressed contents of 'in' to 'out' and
+// closes it
+func compressFile(out, in *os.File, info fs.FileInfo) error {
+	zw := gzip.NewWriter(out)
+	zw.Name = info.Name()
+	zw.ModTime = info.ModTime()
+	// the permissions are restored along with the contents
+	zw.Comment = archiveModePrefix + formatOctalMode(info.Mode())
+
+	if _, err := io.Copy(zw, in); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	// make sure the data hits the disk before the original can be removed
+	if err := out.Sync(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// moveFile renames 'src' to 'dst', falling back to copying the file and
+// removing the original when they are on different file systems.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// copyFile copies the contents, mode and modification time of 'src' to a
+// new file 'dst'.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL,
+		info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,12 +34,129 @@ func TestFilterOut(t *testing.T) {
 			info, err := os.Stat(tc.fileName)
 			assert.Nil(t, err)
 
-			result := filterOut(info, config{
-				ext:     tc.ext,
-				minSize: tc.minSize,
-			})
+			cfg := config{minSize: tc.minSize}
+			if tc.ext != "" {
+				cfg.ext = []string{tc.ext}
+			}
+
+			result := filterOut(tc.fileName, info, cfg)
 
 			assert.Equal(t, tc.filterOut, result)
 		})
 	}
 }
+
+func TestFilterOutPatterns(t *testing.T) {
+	testCases := []struct {
+		testName  string
+		fileName  string
+		cfg       config
+		filterOut bool
+	}{
+		{"MultipleExtensionMatch", "testdata/dir2/script.sh",
+			config{ext: []string{".log", "sh"}}, false},
+		{"MultipleExtensionNoMatch", "testdata/dir2/script.sh",
+			config{ext: []string{".log", ".tmp"}}, true},
+		{"IncludeNameMatch", "testdata/dir.log",
+			config{root: "testdata", include: []string{"*.log"}}, false},
+		{"IncludeNameNoMatch", "testdata/dir.log",
+			config{root: "testdata", include: []string{"*.sh"}}, true},
+		{"IncludePathMatch", "testdata/dir2/script.sh",
+			config{root: "testdata", include: []string{"dir2/*.sh"}}, false},
+		{"ExcludeNameMatch", "testdata/dir.log",
+			config{root: "testdata", exclude: []string{"dir.*"}}, true},
+		{"ExcludeDirOnlyPattern", "testdata/dir.log",
+			config{root: "testdata", exclude: []string{"dir.log/"}}, false},
+		{"IncludeAndExclude", "testdata/dir.log",
+			config{root: "testdata", include: []string{"*.log"},
+				exclude: []string{"*.log"}}, true},
+		{"RegexMatch", "testdata/dir2/script.sh",
+			config{match: regexp.MustCompile(`dir2/.*\.sh$`)}, false},
+		{"RegexNoMatch", "testdata/dir.log",
+			config{match: regexp.MustCompile(`dir2/`)}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			info, err := os.Stat(tc.fileName)
+			assert.Nil(t, err)
+
+			result := filterOut(tc.fileName, info, tc.cfg)
+			assert.Equal(t, tc.filterOut, result)
+		})
+	}
+}
+
+func TestSkipDir(t *testing.T) {
+	testCases := []struct {
+		testName string
+		dirName  string
+		exclude  []string
+		skip     bool
+	}{
+		{"NoExclude", "testdata/dir2", nil, false},
+		{"ExcludeName", "testdata/dir2", []string{"dir2"}, true},
+		{"ExcludeDirPattern", "testdata/dir2", []string{"dir*/"}, true},
+		{"ExcludeNoMatch", "testdata/dir2", []string{"keep/"}, false},
+		{"NeverSkipRoot", "testdata", []string{"testdata"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			info, err := os.Stat(tc.dirName)
+			assert.Nil(t, err)
+
+			cfg := config{root: "testdata", exclude: tc.exclude}
+			assert.Equal(t, tc.skip, skipDir(tc.dirName, info, cfg))
+		})
+	}
+}
+
+func TestOutputDirs(t *testing.T) {
+	absData, err := filepath.Abs("testdata")
+	assert.Nil(t, err)
+	outside, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	testCases := []struct {
+		testName string
+		cfg      config
+		expected map[string]bool
+	}{
+		{testName: "RelativeRootAbsoluteDir", cfg: config{root: "testdata",
+			move: filepath.Join(absData, "dir2")},
+			expected: map[string]bool{filepath.Join("testdata", "dir2"): true}},
+		{testName: "AbsoluteRootRelativeDir", cfg: config{root: absData,
+			sync: "testdata/dir2/"},
+			expected: map[string]bool{filepath.Join(absData, "dir2"): true}},
+		{testName: "RootTrailingSeparator", cfg: config{root: "testdata/",
+			archive: "./testdata/dir2"},
+			expected: map[string]bool{filepath.Join("testdata", "dir2"): true}},
+		{testName: "OutsideRoot", cfg: config{root: "testdata",
+			trashDir: outside}, expected: map[string]bool{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert.Equal(t, tc.expected, outputDirs(tc.cfg))
+		})
+	}
+}
+
+func TestPathDepth(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected int
+	}{
+		{"testdata", 0},
+		{"testdata/dir.log", 1},
+		{"testdata/dir2", 1},
+		{"testdata/dir2/script.sh", 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.expected, pathDepth("testdata", tc.path))
+		})
+	}
+}
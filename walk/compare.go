@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+)
+
+// compareTrees walks 'cfg.root' and 'cfg.compare' with the same filters and
+// reports the files that only exist in one of them and the ones that
+// differ, like diff -rq. Files differ when their types or sizes do and, when
+// 'cfg.compareContent' is set, their contents, otherwise their modification
+// times to the second. Only the contents of regular files are compared.
+func compareTrees(out io.Writer, cfg config) error {
+	left, err := collectFiles(cfg, cfg.root)
+	if err != nil {
+		return err
+	}
+
+	right, err := collectFiles(cfg, cfg.compare)
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(left)+len(right))
+	for rel := range left {
+		paths = append(paths, rel)
+	}
+	for rel := range right {
+		if _, ok := left[rel]; !ok {
+			paths = append(paths, rel)
+		}
+	}
+	sort.Strings(paths)
+
+	var onlyLeft, onlyRight, differ int
+
+	for _, rel := range paths {
+		l, inLeft := left[rel]
+		r, inRight := right[rel]
+
+		switch {
+		case !inRight:
+			fmt.Fprintf(out, "ONLY IN %s: %s\n", cfg.root, rel)
+			onlyLeft++
+		case !inLeft:
+			fmt.Fprintf(out, "ONLY IN %s: %s\n", cfg.compare, rel)
+			onlyRight++
+		default:
+			reason, err := fileDiff(l, r, cfg.compareContent)
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				fmt.Fprintf(out, "DIFFER (%s): %s\n", reason, rel)
+				differ++
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "%d only in %s, %d only in %s, %d different\n",
+		onlyLeft, cfg.root, onlyRight, cfg.compare, differ)
+
+	if diffs := onlyLeft + onlyRight + differ; diffs > 0 {
+		return ErrTreesDiffer.Errorf(diffs)
+	}
+
+	return nil
+}
+
+// collectFiles returns the matched files under 'root' by relative path
+func collectFiles(cfg config, root string) (map[string]fileEntry, error) {
+	cfg.root = root
+	files := map[string]fileEntry{}
+
+	err := walkFiles(cfg, nil, func(path string, info fs.FileInfo) error {
+		if !info.IsDir() {
+			files[relPath(root, path)] = fileEntry{path, info}
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// fileDiff returns why the files differ, or an empty string if they don't
+func fileDiff(a, b fileEntry, content bool) (string, error) {
+	if a.info.Mode().Type() != b.info.Mode().Type() {
+		return "type", nil
+	}
+
+	if a.info.Size() != b.info.Size() {
+		return "size", nil
+	}
+
+	// reading a named pipe would block
+	if !content || !a.info.Mode().IsRegular() {
+		if !sameModTime(a.info.ModTime(), b.info.ModTime()) {
+			return "mtime", nil
+		}
+		return "", nil
+	}
+
+	same, err := sameContents(a.path, b.path)
+	if err != nil || same {
+		return "", err
+	}
+
+	return "content", nil
+}
+
+// sameContents reports whether the files hold the same bytes
+func sameContents(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, 32*1024)
+	bufB := make([]byte, 32*1024)
+
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+
+		// a short read means the end of the file
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		switch {
+		case errA != nil && !endA:
+			return false, errA
+		case errB != nil && !endB:
+			return false, errB
+		case endA || endB:
+			return endA && endB, nil
+		}
+	}
+}
+
+// sameModTime reports whether a copy has the modification time of the
+// original. Copies don't always keep the sub-second part.
+func sameModTime(a, b time.Time) bool {
+	return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
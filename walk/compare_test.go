@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createTree writes the files with their contents and modification time
+func createTree(t *testing.T, files map[string]string,
+	mtime time.Time) (string, func()) {
+	t.Helper()
+
+	tempDir, cleanup := createTempDir(t, nil)
+
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+		assert.Nil(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	return tempDir, cleanup
+}
+
+func TestCompareTrees(t *testing.T) {
+	mtime := time.Date(2022, 5, 1, 10, 0, 0, 0, time.Local)
+
+	left, cleanupLeft := createTree(t, map[string]string{
+		"same.txt":      "same",
+		"size.txt":      "short",
+		"content.txt":   "abcd",
+		"left.txt":      "left",
+		"sub/deep.txt":  "deep",
+		"ignored.other": "left",
+	}, mtime)
+	defer cleanupLeft()
+
+	right, cleanupRight := createTree(t, map[string]string{
+		"same.txt":     "same",
+		"size.txt":     "longer",
+		"content.txt":  "abce",
+		"sub/deep.txt": "deep",
+		"sub/new.txt":  "right",
+	}, mtime)
+	defer cleanupRight()
+
+	// only the time of this file differs
+	later := mtime.Add(time.Hour)
+	assert.Nil(t, os.Chtimes(filepath.Join(right, "same.txt"), later, later))
+
+	testCases := []struct {
+		testName string
+		content  bool
+		expected []string
+	}{
+		{testName: "Times", expected: []string{
+			"ONLY IN " + left + ": left.txt",
+			"DIFFER (mtime): same.txt",
+			"DIFFER (size): size.txt",
+			"ONLY IN " + right + ": sub/new.txt",
+			"1 only in " + left + ", 1 only in " + right + ", 2 different",
+		}},
+		{testName: "Content", content: true, expected: []string{
+			"DIFFER (content): content.txt",
+			"ONLY IN " + left + ": left.txt",
+			"DIFFER (size): size.txt",
+			"ONLY IN " + right + ": sub/new.txt",
+			"1 only in " + left + ", 1 only in " + right + ", 2 different",
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			cfg := config{root: left, compare: right,
+				compareContent: tc.content, ext: []string{".txt"}}
+			assert.Equal(t, ErrTreesDiffer.Errorf(4),
+				compareTrees(&buffer, cfg))
+			assert.Equal(t, strings.Join(tc.expected, "\n")+"\n",
+				buffer.String())
+		})
+	}
+
+	t.Run("Identical", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		cfg := config{root: left, compare: left, compareContent: true}
+		assert.Nil(t, compareTrees(&buffer, cfg))
+	})
+}
+
+func TestSameContents(t *testing.T) {
+	big := strings.Repeat("0123456789", 10000)
+
+	dir, cleanup := createTree(t, map[string]string{
+		"a":     big,
+		"b":     big,
+		"c":     big[:len(big)-1] + "x",
+		"empty": "",
+		"short": big[:50000],
+	}, time.Now())
+	defer cleanup()
+
+	testCases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"a", "b", true},
+		{"a", "c", false},
+		{"a", "short", false},
+		{"empty", "empty", true},
+		{"empty", "a", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.a+"-"+tc.b, func(t *testing.T) {
+			same, err := sameContents(filepath.Join(dir, tc.a),
+				filepath.Join(dir, tc.b))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, same)
+		})
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
 )
 
 // custom configuration related errors
@@ -18,21 +22,160 @@ func (ce ConfigError) Errorf(args ...interface{}) error {
 }
 
 const (
-	ErrDirNotFound = ConfigError("%s: directory not found")
+	ErrDirNotFound     = ConfigError("%s: directory not found")
+	ErrNotDir          = ConfigError("%s: not a directory")
+	ErrNoRestoreSrc    = ConfigError("no archive or trash directory to restore")
+	ErrNoTrashDir      = ConfigError("trash directory not set")
+	ErrFormat          = ConfigError("%s: unsupported output format")
+	ErrNoProfiles      = ConfigError("%s: no profiles defined")
+	ErrProfileNotFound = ConfigError("%s: profile not found")
+	ErrDupesAction     = ConfigError("%s: unsupported duplicates action")
+	ErrKeepPolicy      = ConfigError("%s: unsupported keep policy")
+	ErrDepth           = ConfigError("%d: depth can't be negative")
+	ErrDepthRange      = ConfigError("min depth %d is greater than max depth %d")
+	ErrSymlinks        = ConfigError("%s: unsupported symbolic links policy")
+	ErrCommand         = ConfigError("%s: invalid command")
+	ErrExecFail        = ConfigError("%s: unsupported command failure policy")
+	ErrExecBatch       = ConfigError("-exec-batch files can't be moved or deleted")
+	ErrMoveAction      = ConfigError("-move can't be used with -del or -trash")
+	ErrMoveTarget      = ConfigError("%s: outside of the move directory")
+	ErrCollision       = ConfigError("%s: unsupported collision strategy")
+	ErrFileType        = ConfigError("%s: unsupported file type")
+	ErrDirAction       = ConfigError("directories can't be copied or deleted")
+	ErrManifest        = ConfigError("%s: not a manifest")
+	ErrManifestDrift   = ConfigError("%d difference(s) from the manifest")
+	ErrTreesDiffer     = ConfigError("%d difference(s) between the trees")
+	ErrNotRegular      = ConfigError("%s: not a regular file")
+	ErrKeepLast        = ConfigError("%d: number of kept files can't be negative")
+	ErrWatchOption     = ConfigError("%s can't be used with -watch")
+	ErrWatchInterval   = ConfigError("%s: watch interval must be positive")
+	ErrNotRestored     = ConfigError("%d file(s) could not be restored")
+	ErrNumWorkers      = ConfigError("%d: number of workers must be at least 1")
+	ErrSizeRange       = ConfigError("min size %d is greater than max size %d")
+	ErrTimeRange       = ConfigError("no file can be both newer than %s and " +
+		"older than %s")
 )
 
 // all the configuration options
 type config struct {
 	// root directory to start searching from
 	root string
-	// extension
-	ext string
+	// max depth below root, 0 means no limit
+	maxDepth int
+	// min depth below root of the matched files
+	minDepth int
+	// stay on the file system of root
+	xdev bool
+	// symbolic links policy
+	symlinks string
+	// names of the gitignore-syntax files to apply
+	ignoreFiles []string
+	// apply the .gitignore files and skip the .git directories
+	gitignore bool
+	// extensions
+	ext []string
+	// glob patterns files must match
+	include []string
+	// glob patterns of files and directories to skip
+	exclude []string
+	// regular expression files must match
+	match *regexp.Regexp
+	// letters of the file types to match
+	types string
+	// only match files with an execute permission bit set
+	executable bool
+	// permissions files must match
+	perm permFlag
+	// user and group IDs files must belong to
+	uid string
+	gid string
+	// only match files whose owner or group doesn't exist
+	noUser  bool
+	noGroup bool
 	// min file size
 	minSize uint64
+	// max file size, 0 means no limit
+	maxSize uint64
+	// only match files modified before this time
+	olderThan time.Time
+	// only match files modified after this time
+	newerThan time.Time
 	// list files
 	list bool
 	// delete fies
 	del bool
+	// archive directory
+	archive string
+	// move files to the trash
+	trash bool
+	// make 'del' move files to the trash instead of removing them
+	safeDel bool
+	// trash directory
+	trashDir string
+	// directory to move files into
+	move string
+	// path of the moved files inside 'move'
+	moveTemplate *template.Template
+	// what to do when the destination of a moved file exists
+	collision string
+	// directory to copy files to
+	sync string
+	// delete the files in 'sync' that weren't copied
+	syncDelete bool
+	// change of the permissions of the files
+	chmod modeFlag
+	// new owner and group of the files
+	chown ownerFlag
+	// command run for every file
+	exec string
+	// command run for batches of files
+	execBatch string
+	// what to do when a command fails
+	execFail string
+	// runs the commands, set when running
+	runner *executor
+	// purge the trash
+	emptyTrash bool
+	// delete log to restore files from
+	restore string
+	// find duplicate files
+	dupes bool
+	// what to do with the duplicates
+	dupesAction string
+	// which duplicate to keep
+	keep string
+	// manifest to write
+	manifest string
+	// manifest to verify the files against
+	checkManifest string
+	// directory to compare the root with
+	compare string
+	// compare the contents of the files instead of their times
+	compareContent bool
+	// report the disk usage of the matched files
+	du bool
+	// depth of the directories in the disk usage report
+	duDepth int
+	// number of newest files of every directory exempt from the actions
+	keepLast int
+	// paths of the files exempt from the actions, set when running
+	kept map[string]bool
+	// output directories never walked, set when walking
+	outputDirs map[string]bool
+	// keep applying the actions to the files that appear or change
+	watch bool
+	// how often the watch scans for changes
+	watchInterval time.Duration
+	// only report what would be done
+	dryRun bool
+	// number of files processed concurrently
+	workers int
+	// output format
+	format string
+	// print a summary in text format
+	summary bool
+	// reporter shared by the profiles run together, set when running them
+	reporter reporter
 	// log destination writer
 	wLog io.Writer
 }
@@ -43,13 +186,17 @@ func (c *config) configure(logFile string) error {
 		f         = os.Stdout
 		err error = nil
 	)
+	// keep the log out of structured output
+	if c.format != "" && c.format != formatText {
+		f = os.Stderr
+	}
 	if logFile != "" {
 		f, err = os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
 		if err != nil {
 			return err
 		}
-
-		defer f.Close()
+		// the file stays open until the run ends so that every action can be
+		// logged, the caller closes it
 	}
 	c.wLog = f
 
@@ -64,5 +211,153 @@ func (c *config) verify() error {
 		}
 	}
 
+	switch c.format {
+	case "", formatText, formatJSON, formatCSV, formatNDJSON:
+	default:
+		return ErrFormat.Errorf(c.format)
+	}
+
+	switch c.symlinks {
+	case "", symlinksFile, symlinksSkip, symlinksFollow, symlinksOnly:
+	default:
+		return ErrSymlinks.Errorf(c.symlinks)
+	}
+
+	switch c.execFail {
+	case "", execFailContinue, execFailStop, execFailIgnore:
+	default:
+		return ErrExecFail.Errorf(c.execFail)
+	}
+
+	for _, command := range []string{c.exec, c.execBatch} {
+		if command == "" {
+			continue
+		}
+		if _, err := splitCommand(command); err != nil {
+			return err
+		}
+	}
+
+	switch c.collision {
+	case "", collisionSkip, collisionOverwrite, collisionSuffix:
+	default:
+		return ErrCollision.Errorf(c.collision)
+	}
+
+	if c.move != "" && (c.del || c.trash) {
+		return ErrMoveAction
+	}
+
+	// the batch command runs after the files are processed
+	if c.execBatch != "" && (c.del || c.trash || c.archive != "" ||
+		c.move != "") {
+		return ErrExecBatch
+	}
+
+	// types can be separated by commas, as in find
+	for _, t := range c.types {
+		if !strings.ContainsRune("fdlps,", t) {
+			return ErrFileType.Errorf(string(t))
+		}
+	}
+
+	// the other actions and modes only apply to files
+	if strings.ContainsRune(c.types, fileTypeDir) && (c.del || c.trash ||
+		c.archive != "" || c.move != "" || c.sync != "" || c.dupes || c.du ||
+		c.manifest != "" || c.checkManifest != "" || c.compare != "") {
+		return ErrDirAction
+	}
+
+	// the watch never ends, so nothing can run after every file is processed
+	if c.watch {
+		switch {
+		case c.execBatch != "":
+			return ErrWatchOption.Errorf("-exec-batch")
+		case c.syncDelete:
+			return ErrWatchOption.Errorf("-sync-delete")
+		case c.watchInterval <= 0:
+			return ErrWatchInterval.Errorf(c.watchInterval)
+		}
+	}
+
+	switch c.dupesAction {
+	case "", dupesReport, dupesDelete, dupesHardlink:
+	default:
+		return ErrDupesAction.Errorf(c.dupesAction)
+	}
+
+	switch c.keep {
+	case "", keepOldest, keepNewest, keepShortest:
+	default:
+		return ErrKeepPolicy.Errorf(c.keep)
+	}
+
+	if c.keepLast < 0 {
+		return ErrKeepLast.Errorf(c.keepLast)
+	}
+
+	if c.workers < 1 {
+		return ErrNumWorkers.Errorf(c.workers)
+	}
+
+	for _, depth := range []int{c.minDepth, c.maxDepth, c.duDepth} {
+		if depth < 0 {
+			return ErrDepth.Errorf(depth)
+		}
+	}
+
+	if c.maxDepth > 0 && c.minDepth > c.maxDepth {
+		return ErrDepthRange.Errorf(c.minDepth, c.maxDepth)
+	}
+
+	if c.maxSize > 0 && c.minSize > c.maxSize {
+		return ErrSizeRange.Errorf(c.minSize, c.maxSize)
+	}
+
+	// the age filters must leave a window files can fall in
+	if !c.olderThan.IsZero() && !c.newerThan.IsZero() &&
+		!c.newerThan.Before(c.olderThan) {
+		return ErrTimeRange.Errorf(c.newerThan.Format(time.RFC3339),
+			c.olderThan.Format(time.RFC3339))
+	}
+
+	// restoring needs somewhere to restore the files from
+	if c.restore != "" && c.archive == "" && c.trashDir == "" {
+		return ErrNoRestoreSrc
+	}
+
+	if (c.trash || c.safeDel || c.emptyTrash) && c.trashDir == "" {
+		return ErrNoTrashDir
+	}
+
+	// verify the archive and compared directories exist and are directories
+	for _, dir := range []string{c.archive, c.compare} {
+		if dir == "" {
+			continue
+		}
+
+		info, err := os.Stat(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return ErrDirNotFound.Errorf(dir)
+			}
+			return err
+		}
+
+		if !info.IsDir() {
+			return ErrNotDir.Errorf(dir)
+		}
+	}
+
+	// the move and sync directories are created when needed
+	for _, dir := range []string{c.move, c.sync} {
+		if dir == "" {
+			continue
+		}
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			return ErrNotDir.Errorf(dir)
+		}
+	}
+
 	return nil
 }
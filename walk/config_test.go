@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		testName string
+		cfg      config
+		expected error
+	}{
+		{testName: "Valid", cfg: config{root: "testdata", workers: 1},
+			expected: nil},
+		{testName: "RootNotFound", cfg: config{root: "missing", workers: 1},
+			expected: ErrDirNotFound.Errorf("missing")},
+		{testName: "UnsupportedFormat", cfg: config{root: "testdata",
+			workers: 1, format: "xml"}, expected: ErrFormat.Errorf("xml")},
+		{testName: "UnsupportedSymlinks", cfg: config{root: "testdata",
+			workers: 1, symlinks: "ignore"},
+			expected: ErrSymlinks.Errorf("ignore")},
+		{testName: "UnsupportedDupesAction", cfg: config{root: "testdata",
+			workers: 1, dupesAction: "move"},
+			expected: ErrDupesAction.Errorf("move")},
+		{testName: "UnsupportedKeepPolicy", cfg: config{root: "testdata",
+			workers: 1, keep: "largest"},
+			expected: ErrKeepPolicy.Errorf("largest")},
+		{testName: "NoWorkers", cfg: config{root: "testdata"},
+			expected: ErrNumWorkers.Errorf(0)},
+		{testName: "ArchiveNotDir", cfg: config{root: "testdata", workers: 1,
+			archive: "testdata/dir.log"},
+			expected: ErrNotDir.Errorf("testdata/dir.log")},
+		{testName: "RestoreNoArchive", cfg: config{root: "testdata",
+			workers: 1, restore: "testdata/dir.log"},
+			expected: ErrNoRestoreSrc},
+		{testName: "UnsupportedExecFail", cfg: config{root: "testdata",
+			workers: 1, execFail: "retry"},
+			expected: ErrExecFail.Errorf("retry")},
+		{testName: "InvalidCommand", cfg: config{root: "testdata",
+			workers: 1, exec: `echo "{}`},
+			expected: ErrCommand.Errorf(`echo "{}`)},
+		{testName: "ExecBatchDelete", cfg: config{root: "testdata",
+			workers: 1, execBatch: "rm", del: true},
+			expected: ErrExecBatch},
+		{testName: "NegativeKeepLast", cfg: config{root: "testdata",
+			workers: 1, keepLast: -1}, expected: ErrKeepLast.Errorf(-1)},
+		{testName: "WatchExecBatch", cfg: config{root: "testdata",
+			workers: 1, watch: true, watchInterval: time.Second,
+			execBatch: "rm"}, expected: ErrWatchOption.Errorf("-exec-batch")},
+		{testName: "WatchInterval", cfg: config{root: "testdata", workers: 1,
+			watch: true}, expected: ErrWatchInterval.Errorf(time.Duration(0))},
+		{testName: "UnsupportedCollision", cfg: config{root: "testdata",
+			workers: 1, move: "sorted", collision: "rename"},
+			expected: ErrCollision.Errorf("rename")},
+		{testName: "MoveDelete", cfg: config{root: "testdata", workers: 1,
+			move: "sorted", del: true}, expected: ErrMoveAction},
+		{testName: "MoveNotDir", cfg: config{root: "testdata", workers: 1,
+			move: "testdata/dir.log"},
+			expected: ErrNotDir.Errorf("testdata/dir.log")},
+		{testName: "UnsupportedFileType", cfg: config{root: "testdata",
+			workers: 1, types: "f,x"}, expected: ErrFileType.Errorf("x")},
+		{testName: "DeleteDirectories", cfg: config{root: "testdata",
+			workers: 1, types: "d", del: true}, expected: ErrDirAction},
+		{testName: "MoveDirectories", cfg: config{root: "testdata",
+			workers: 1, types: "d", move: "sorted"}, expected: ErrDirAction},
+		{testName: "ManifestDirectories", cfg: config{root: "testdata",
+			workers: 1, types: "f,d", manifest: "files.csv"},
+			expected: ErrDirAction},
+		{testName: "VerifyDirectories", cfg: config{root: "testdata",
+			workers: 1, types: "d", checkManifest: "files.csv"},
+			expected: ErrDirAction},
+		{testName: "CompareDirectories", cfg: config{root: "testdata",
+			workers: 1, types: "d", compare: "testdata/dir2"},
+			expected: ErrDirAction},
+		{testName: "CompareNotDir", cfg: config{root: "testdata", workers: 1,
+			compare: "testdata/dir.log"},
+			expected: ErrNotDir.Errorf("testdata/dir.log")},
+		{testName: "NegativeDepth", cfg: config{root: "testdata", workers: 1,
+			maxDepth: -1}, expected: ErrDepth.Errorf(-1)},
+		{testName: "NegativeDuDepth", cfg: config{root: "testdata",
+			workers: 1, du: true, duDepth: -2}, expected: ErrDepth.Errorf(-2)},
+		{testName: "DepthRange", cfg: config{root: "testdata", workers: 1,
+			minDepth: 3, maxDepth: 2}, expected: ErrDepthRange.Errorf(3, 2)},
+		{testName: "SizeRange", cfg: config{root: "testdata", workers: 1,
+			minSize: 20, maxSize: 10},
+			expected: ErrSizeRange.Errorf(20, 10)},
+		{testName: "SizeNoMax", cfg: config{root: "testdata", workers: 1,
+			minSize: 20}, expected: nil},
+		{testName: "TimeRange", cfg: config{root: "testdata", workers: 1,
+			olderThan: now.AddDate(0, 0, -30), newerThan: now},
+			expected: ErrTimeRange.Errorf(now.Format(time.RFC3339),
+				now.AddDate(0, 0, -30).Format(time.RFC3339))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.cfg.verify())
+		})
+	}
+}
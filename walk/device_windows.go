@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "io/fs"
+
+// deviceID isn't supported on Windows, file systems are never crossed
+func deviceID(info fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
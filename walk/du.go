@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// dirUsage holds the totals of the matched files below a directory
+type dirUsage struct {
+	path  string
+	files int
+	bytes int64
+}
+
+// diskUsage adds up the sizes of the matched files for every directory up to
+// 'cfg.duDepth' levels below the root, like du does, and prints them largest
+// first along with their share of the total.
+func diskUsage(out io.Writer, cfg config) error {
+	root := &dirUsage{path: cfg.root}
+	usage := map[string]*dirUsage{cfg.root: root}
+
+	err := walkFiles(cfg, nil, func(path string, info fs.FileInfo) error {
+		for _, dir := range usageDirs(cfg.root, path, cfg.duDepth) {
+			u, ok := usage[dir]
+			if !ok {
+				u = &dirUsage{path: dir}
+				usage[dir] = u
+			}
+			u.files++
+			u.bytes += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	dirs := make([]*dirUsage, 0, len(usage))
+	for _, u := range usage {
+		dirs = append(dirs, u)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].bytes != dirs[j].bytes {
+			return dirs[i].bytes > dirs[j].bytes
+		}
+		return dirs[i].path < dirs[j].path
+	})
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', tabwriter.AlignRight)
+	for _, u := range dirs {
+		percent := 0.0
+		if root.bytes > 0 {
+			percent = float64(u.bytes) * 100 / float64(root.bytes)
+		}
+		fmt.Fprintf(w, "%s\t%.1f%%\t%d\t  %s\n", formatSize(u.bytes), percent,
+			u.files, u.path)
+	}
+
+	return w.Flush()
+}
+
+// usageDirs returns the directories the file at 'path' counts towards: the
+// root and its ancestors up to 'depth' levels below the root.
+func usageDirs(root, path string, depth int) []string {
+	dirs := []string{root}
+
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil || rel == "." {
+		return dirs
+	}
+
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i := 1; i <= depth && i <= len(parts); i++ {
+		dirs = append(dirs, filepath.Join(root, filepath.Join(parts[:i]...)))
+	}
+
+	return dirs
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageDirs(t *testing.T) {
+	root := filepath.FromSlash("/data")
+	path := filepath.Join(root, "a", "b", "c", "file.log")
+
+	testCases := []struct {
+		testName string
+		depth    int
+		expected []string
+	}{
+		{testName: "Total", depth: 0, expected: []string{""}},
+		{testName: "OneLevel", depth: 1, expected: []string{"", "a"}},
+		{testName: "AllLevels", depth: 5,
+			expected: []string{"", "a", "a/b", "a/b/c"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			expected := []string{}
+			for _, dir := range tc.expected {
+				expected = append(expected,
+					filepath.Join(root, filepath.FromSlash(dir)))
+			}
+			assert.Equal(t, expected, usageDirs(root, path, tc.depth))
+		})
+	}
+
+	assert.Equal(t, []string{root},
+		usageDirs(root, filepath.Join(root, "file.log"), 3))
+}
+
+func TestDiskUsage(t *testing.T) {
+	var buffer bytes.Buffer
+
+	files := map[string]int{
+		"top.log":         100,
+		"big/a.log":       500,
+		"big/deep/b.log":  300,
+		"small/c.log":     100,
+		"small/d.tmp":     1000,
+		"empty/ignored.x": 10,
+	}
+
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	for name, size := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, make([]byte, size), 0644))
+	}
+
+	cfg := config{root: tempDir, ext: []string{".log"}, du: true, duDepth: 1}
+	assert.Nil(t, diskUsage(&buffer, cfg))
+
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	expected := []struct {
+		size    string
+		percent string
+		files   string
+		path    string
+	}{
+		{"1000B", "100.0%", "4", tempDir},
+		{"800B", "80.0%", "2", filepath.Join(tempDir, "big")},
+		{"100B", "10.0%", "1", filepath.Join(tempDir, "small")},
+	}
+
+	assert.Equal(t, len(expected), len(lines))
+	for i, e := range expected {
+		assert.Equal(t, []string{e.size, e.percent, e.files, e.path},
+			strings.Fields(lines[i]))
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// what to do with the duplicates of the kept file
+const (
+	dupesReport   = "report"
+	dupesDelete   = "delete"
+	dupesHardlink = "hardlink"
+)
+
+// which file of a duplicate set is kept
+const (
+	keepOldest   = "oldest"
+	keepNewest   = "newest"
+	keepShortest = "shortest"
+)
+
+// fileEntry is a matched file collected by the walk
+type fileEntry struct {
+	path string
+	info fs.FileInfo
+}
+
+// dupeSet is a group of files with the same contents, kept file first
+type dupeSet struct {
+	hash  string
+	files []fileEntry
+}
+
+// findDupes groups the matched files by size and then by SHA-256, only
+// hashing the files that have the same size as another one. Every set of
+// duplicates is reported and, depending on 'cfg.dupesAction', all files but
+// the one selected by 'cfg.keep' are deleted or replaced by hard links.
+func findDupes(out io.Writer, cfg config) error {
+	bySize := map[int64][]fileEntry{}
+
+	err := walkFiles(cfg, nil, func(path string, info fs.FileInfo) error {
+		// empty files are all identical, don't report them. Only regular
+		// files are compared, a link isn't a copy of its target
+		if info.Mode().IsRegular() && info.Size() > 0 {
+			bySize[info.Size()] = append(bySize[info.Size()],
+				fileEntry{path, info})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sets, errs := groupDupes(bySize)
+	for _, set := range sets {
+		sortKeep(set.files, cfg.keep)
+	}
+
+	logs := newLoggers(out, cfg)
+	var dupes int
+	var reclaimable int64
+
+	for _, set := range sets {
+		size := set.files[0].info.Size()
+		fmt.Fprintf(out, "sha256:%s (%s x %d)\n", set.hash, formatSize(size),
+			len(set.files))
+		fmt.Fprintf(out, "  KEEP %s\n", set.files[0].path)
+
+		for _, dupe := range set.files[1:] {
+			fmt.Fprintf(out, "  DUPE %s\n", dupe.path)
+			dupes++
+			reclaimable += size
+
+			if err := resolveDupe(set.files[0].path, dupe.path, cfg,
+				logs); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "%d duplicate set(s), %d duplicate file(s), %s "+
+		"reclaimable\n", len(sets), dupes, formatSize(reclaimable))
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// groupDupes hashes the files sharing a size and returns the sets of files
+// with identical contents, ordered by size and hash so the output is stable.
+func groupDupes(bySize map[int64][]fileEntry) ([]dupeSet, actionErrors) {
+	var (
+		sets []dupeSet
+		errs actionErrors
+	)
+
+	for _, candidates := range bySize {
+		candidates = distinctFiles(candidates)
+		if len(candidates) < 2 {
+			continue
+		}
+
+		byHash := map[string][]fileEntry{}
+		for _, entry := range candidates {
+			hash, err := hashFile(entry.path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			byHash[hash] = append(byHash[hash], entry)
+		}
+
+		for hash, files := range byHash {
+			if len(files) > 1 {
+				sets = append(sets, dupeSet{hash: hash, files: files})
+			}
+		}
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		si, sj := sets[i].files[0].info.Size(), sets[j].files[0].info.Size()
+		if si != sj {
+			return si > sj
+		}
+		return sets[i].hash < sets[j].hash
+	})
+
+	return sets, errs
+}
+
+// distinctFiles drops the entries that are hard links to an earlier one,
+// they already share their contents and take no extra space.
+func distinctFiles(entries []fileEntry) []fileEntry {
+	distinct := entries[:0:0]
+
+	for _, entry := range entries {
+		linked := false
+		for _, other := range distinct {
+			if os.SameFile(entry.info, other.info) {
+				linked = true
+				break
+			}
+		}
+
+		if !linked {
+			distinct = append(distinct, entry)
+		}
+	}
+
+	return distinct
+}
+
+// hashFile returns the hex encoded SHA-256 of the contents of 'path'
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sortKeep orders the files so that the one to keep according to 'policy'
+// comes first. Ties are broken by path.
+func sortKeep(files []fileEntry, policy string) {
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+
+		switch policy {
+		case keepNewest:
+			if !a.info.ModTime().Equal(b.info.ModTime()) {
+				return a.info.ModTime().After(b.info.ModTime())
+			}
+		case keepShortest:
+			if len(a.path) != len(b.path) {
+				return len(a.path) < len(b.path)
+			}
+		default:
+			if !a.info.ModTime().Equal(b.info.ModTime()) {
+				return a.info.ModTime().Before(b.info.ModTime())
+			}
+		}
+
+		return a.path < b.path
+	})
+}
+
+// resolveDupe applies the configured action to a duplicate of 'keep'. The
+// deleted duplicates have their own log prefix, a copy of them is kept so
+// the restore mode doesn't replay them.
+func resolveDupe(keep, dupe string, cfg config, logs loggers) error {
+	switch cfg.dupesAction {
+	case dupesDelete:
+		return deleteFile(dupe, logs.dupe, cfg.dryRun)
+	case dupesHardlink:
+		return linkFile(keep, dupe, logs.link, cfg.dryRun)
+	default:
+		return nil
+	}
+}
+
+// linkFile replaces 'dupe' with a hard link to 'keep'. The link is created
+// next to 'dupe' and renamed over it, so 'dupe' is never missing.
+func linkFile(keep, dupe string, linkLogger *log.Logger, dryRun bool) error {
+	if !dryRun {
+		tmp := filepath.Join(filepath.Dir(dupe),
+			fmt.Sprintf(".%s.walk-link", filepath.Base(dupe)))
+
+		if err := os.Link(keep, tmp); err != nil {
+			return err
+		}
+
+		if err := os.Rename(tmp, dupe); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+
+	linkLogger.Printf("%s -> %s", dupe, keep)
+	return nil
+}
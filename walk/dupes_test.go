@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createDupesDir creates files with the given contents, each one a day older
+// than the previous one
+func createDupesDir(t *testing.T, files []string,
+	contents map[string]string) (string, func()) {
+	t.Helper()
+
+	tempDir, cleanup := createTempDir(t, nil)
+	now := time.Now()
+
+	for i, name := range files {
+		path := filepath.Join(tempDir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(contents[name]), 0644))
+
+		mtime := now.AddDate(0, 0, -i)
+		assert.Nil(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	return tempDir, cleanup
+}
+
+func TestFindDupes(t *testing.T) {
+	files := []string{"a.txt", "sub/b.txt", "sub/deeper/c.txt", "d.txt",
+		"e.txt", "empty1.txt", "empty2.txt"}
+	contents := map[string]string{
+		"a.txt":            "same",
+		"sub/b.txt":        "same",
+		"sub/deeper/c.txt": "same",
+		// same size, different contents
+		"d.txt": "diff",
+		"e.txt": "other content",
+	}
+
+	testCases := []struct {
+		testName string
+		cfg      config
+		kept     string
+		removed  []string
+	}{
+		{testName: "ReportOnly", cfg: config{},
+			kept: "sub/deeper/c.txt"},
+		{testName: "DeleteKeepOldest", cfg: config{dupesAction: dupesDelete,
+			keep: keepOldest}, kept: "sub/deeper/c.txt",
+			removed: []string{"a.txt", "sub/b.txt"}},
+		{testName: "DeleteKeepNewest", cfg: config{dupesAction: dupesDelete,
+			keep: keepNewest}, kept: "a.txt",
+			removed: []string{"sub/b.txt", "sub/deeper/c.txt"}},
+		{testName: "DeleteKeepShortest", cfg: config{dupesAction: dupesDelete,
+			keep: keepShortest}, kept: "a.txt",
+			removed: []string{"sub/b.txt", "sub/deeper/c.txt"}},
+		{testName: "DeleteDryRun", cfg: config{dupesAction: dupesDelete,
+			keep: keepNewest, dryRun: true}, kept: "a.txt"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var (
+				buffer    bytes.Buffer
+				logBuffer bytes.Buffer
+			)
+
+			tempDir, cleanup := createDupesDir(t, files, contents)
+			defer cleanup()
+
+			tc.cfg.root = tempDir
+			tc.cfg.wLog = &logBuffer
+			assert.Nil(t, findDupes(&buffer, tc.cfg))
+
+			result := buffer.String()
+			assert.Equal(t, 1, strings.Count(result, "sha256:"))
+			assert.Contains(t, result,
+				"  KEEP "+filepath.Join(tempDir, tc.kept)+"\n")
+			assert.Equal(t, 2, strings.Count(result, "  DUPE "))
+			assert.Contains(t, result, "1 duplicate set(s), 2 duplicate "+
+				"file(s), 8B reclaimable\n")
+
+			// the deleted duplicates aren't restored, a copy is kept
+			logs := logBuffer.String()
+			assert.Equal(t, len(tc.removed),
+				strings.Count(logs, dupeLogPrefix))
+			assert.Equal(t, 0, strings.Count(logs, delLogPrefix))
+
+			for _, name := range files {
+				_, err := os.Stat(filepath.Join(tempDir, name))
+				removed := false
+				for _, r := range tc.removed {
+					removed = removed || r == name
+				}
+				assert.Equal(t, removed, os.IsNotExist(err), name)
+			}
+		})
+	}
+}
+
+func TestFindDupesHardlink(t *testing.T) {
+	var buffer bytes.Buffer
+
+	files := []string{"a.txt", "b.txt", "c.txt"}
+	contents := map[string]string{"a.txt": "same", "b.txt": "same",
+		"c.txt": "same"}
+
+	tempDir, cleanup := createDupesDir(t, files, contents)
+	defer cleanup()
+
+	cfg := config{root: tempDir, dupesAction: dupesHardlink,
+		keep: keepNewest, wLog: &buffer}
+	assert.Nil(t, findDupes(&buffer, cfg))
+	assert.Equal(t, 2, strings.Count(buffer.String(), linkLogPrefix))
+
+	kept, err := os.Stat(filepath.Join(tempDir, "a.txt"))
+	assert.Nil(t, err)
+
+	for _, name := range files[1:] {
+		info, err := os.Stat(filepath.Join(tempDir, name))
+		assert.Nil(t, err)
+		assert.True(t, os.SameFile(kept, info), name)
+	}
+
+	// no temporary links are left behind
+	entries, err := os.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(entries))
+
+	// files already linked aren't duplicates anymore
+	buffer.Reset()
+	assert.Nil(t, findDupes(&buffer, cfg))
+	assert.Contains(t, buffer.String(), "0 duplicate set(s), 0 duplicate "+
+		"file(s), 0B reclaimable\n")
+	assert.Equal(t, 0, strings.Count(buffer.String(), linkLogPrefix))
+}
+
+func TestFindDupesSymlink(t *testing.T) {
+	var buffer bytes.Buffer
+
+	// the link is as long as its target and its path shorter, it'd be kept
+	tempDir, cleanup := createDupesDir(t, []string{"bbbb"},
+		map[string]string{"bbbb": "same"})
+	defer cleanup()
+	assert.Nil(t, os.Symlink("bbbb", filepath.Join(tempDir, "a")))
+
+	cfg := config{root: tempDir, dupesAction: dupesDelete, keep: keepShortest,
+		symlinks: symlinksFile, wLog: &buffer}
+	assert.Nil(t, findDupes(&buffer, cfg))
+	assert.Contains(t, buffer.String(), "0 duplicate set(s)")
+
+	_, err := os.Stat(filepath.Join(tempDir, "bbbb"))
+	assert.Nil(t, err)
+}
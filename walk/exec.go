@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// what to do when a command fails
+const (
+	execFailContinue = "continue"
+	execFailStop     = "stop"
+	execFailIgnore   = "ignore"
+)
+
+// maxBatchLen is the maximum length of the paths passed to a batch command,
+// below the command line limit of every platform
+const maxBatchLen = 30000
+
+// placeholder replaced by the path of the matched files
+const pathPlaceholder = "{}"
+
+// errStopped ends the walk once a command failed with the stop policy
+var errStopped = errors.New("stopped after a command failed")
+
+// commandError is returned when a command can't be started or exits with a
+// non-zero status
+type commandError struct {
+	command string
+	err     error
+}
+
+func (ce *commandError) Error() string {
+	return fmt.Sprintf("%s: %v", ce.command, ce.err)
+}
+
+func (ce *commandError) Unwrap() error {
+	return ce.err
+}
+
+// isCommandError reports whether 'err' is a failed command
+func isCommandError(err error) bool {
+	var ce *commandError
+	return errors.As(err, &ce)
+}
+
+// splitCommand splits a command line into its arguments. Arguments can be
+// quoted with single or double quotes to include spaces.
+func splitCommand(command string) ([]string, error) {
+	var (
+		args  []string
+		arg   strings.Builder
+		inArg bool
+		quote rune
+	)
+
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	if quote != 0 || len(args) == 0 {
+		return nil, ErrCommand.Errorf(command)
+	}
+
+	return args, nil
+}
+
+// executor runs the commands of the exec actions, keeping track of their
+// failures. It's shared by the workers.
+type executor struct {
+	// command run for every file
+	fileArgs []string
+	// command run for batches of files
+	batchArgs []string
+	policy    string
+	dryRun    bool
+
+	mu     sync.Mutex
+	failed bool
+	// files waiting for the batch command
+	batch    []string
+	batchLen int
+}
+
+func newExecutor(cfg config) (*executor, error) {
+	e := &executor{policy: cfg.execFail, dryRun: cfg.dryRun}
+
+	var err error
+	if cfg.exec != "" {
+		if e.fileArgs, err = splitCommand(cfg.exec); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.execBatch != "" {
+		if e.batchArgs, err = splitCommand(cfg.execBatch); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// runFile runs the per file command on 'path'. Every '{}' in the arguments
+// is replaced by the path, which is appended when there's none.
+func (e *executor) runFile(path string, out io.Writer,
+	logger *log.Logger) error {
+	args := make([]string, len(e.fileArgs))
+	found := false
+	for i, arg := range e.fileArgs {
+		args[i] = strings.ReplaceAll(arg, pathPlaceholder, path)
+		found = found || args[i] != arg
+	}
+	if !found {
+		args = append(args, path)
+	}
+
+	return e.command(args, strings.Join(args, " "), out, logger)
+}
+
+// add queues 'path' for the batch command, running it when the batch is
+// full.
+func (e *executor) add(path string, out io.Writer, logger *log.Logger) error {
+	if e.batchLen+len(path) > maxBatchLen {
+		if err := e.flush(out, logger); err != nil {
+			return err
+		}
+	}
+
+	e.batch = append(e.batch, path)
+	e.batchLen += len(path) + 1
+
+	return nil
+}
+
+// flush runs the batch command on the queued files. An argument that is
+// exactly '{}' is replaced by the files, which are appended when there's
+// none.
+func (e *executor) flush(out io.Writer, logger *log.Logger) error {
+	if len(e.batch) == 0 {
+		return nil
+	}
+
+	files := e.batch
+	e.batch, e.batchLen = nil, 0
+
+	args := []string{}
+	found := false
+	for _, arg := range e.batchArgs {
+		if arg == pathPlaceholder {
+			args = append(args, files...)
+			found = true
+			continue
+		}
+		args = append(args, arg)
+	}
+	if !found {
+		args = append(args, files...)
+	}
+
+	name := fmt.Sprintf("%s (%d files)", e.batchArgs[0], len(files))
+	return e.command(args, name, out, logger)
+}
+
+// command runs 'args', sending its output to 'out'. Failures are returned
+// as a commandError, unless the policy is to ignore them.
+func (e *executor) command(args []string, name string, out io.Writer,
+	logger *log.Logger) error {
+	logger.Println(strings.Join(args, " "))
+	if e.dryRun {
+		return nil
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = out
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err == nil || e.policy == execFailIgnore {
+		return nil
+	}
+
+	e.mu.Lock()
+	e.failed = true
+	e.mu.Unlock()
+
+	return &commandError{command: name, err: err}
+}
+
+// stopped reports whether a command failed and the policy is to stop
+func (e *executor) stopped() bool {
+	if e == nil || e.policy != execFailStop {
+		return false
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.failed
+}
+
+// commandOutput returns where the output of the commands is sent, keeping it
+// out of structured output.
+func commandOutput(out io.Writer, cfg config) io.Writer {
+	if cfg.format != "" && cfg.format != formatText {
+		return cfg.wLog
+	}
+
+	return out
+}
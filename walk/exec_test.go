@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCommand(t *testing.T) {
+	testCases := []struct {
+		testName string
+		command  string
+		expected []string
+		fails    bool
+	}{
+		{testName: "Simple", command: "gzip -9 {}",
+			expected: []string{"gzip", "-9", "{}"}},
+		{testName: "Spaces", command: "  ls   -l  ",
+			expected: []string{"ls", "-l"}},
+		{testName: "DoubleQuotes", command: `sh -c "echo {} done"`,
+			expected: []string{"sh", "-c", "echo {} done"}},
+		{testName: "SingleQuotes", command: `grep -l 'a "b"' {}`,
+			expected: []string{"grep", "-l", `a "b"`, "{}"}},
+		{testName: "EmptyQuotes", command: `printf ''`,
+			expected: []string{"printf", ""}},
+		{testName: "Unterminated", command: `sh -c "echo`, fails: true},
+		{testName: "Empty", command: "   ", fails: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			args, err := splitCommand(tc.command)
+			if tc.fails {
+				assert.Equal(t, ErrCommand.Errorf(tc.command), err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, args)
+		})
+	}
+}
+
+func TestRunExec(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 3,
+		".txt": 1})
+	defer cleanup()
+
+	testCases := []struct {
+		testName string
+		cfg      config
+		lines    int
+		logs     int
+	}{
+		{testName: "PerFile", cfg: config{exec: `sh -c "echo seen {}"`},
+			lines: 3, logs: 3},
+		{testName: "PathAppended", cfg: config{exec: "echo seen"},
+			lines: 3, logs: 3},
+		{testName: "Batch", cfg: config{execBatch: "echo seen {}"},
+			lines: 1, logs: 1},
+		{testName: "Workers", cfg: config{exec: "echo seen", workers: 3},
+			lines: 3, logs: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer, logBuffer bytes.Buffer
+
+			tc.cfg.root = tempDir
+			tc.cfg.ext = []string{".log"}
+			tc.cfg.wLog = &logBuffer
+			assert.Nil(t, run(&buffer, tc.cfg))
+
+			result := buffer.String()
+			assert.Equal(t, tc.lines, strings.Count(result, "seen "))
+			assert.Equal(t, 3, strings.Count(result, ".log"))
+			assert.NotContains(t, result, ".txt")
+			assert.Equal(t, tc.logs,
+				strings.Count(logBuffer.String(), execLogPrefix))
+		})
+	}
+}
+
+func TestRunExecFailures(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	testCases := []struct {
+		testName string
+		execFail string
+		runs     int
+		failures int
+	}{
+		{testName: "Continue", execFail: execFailContinue, runs: 3,
+			failures: 3},
+		{testName: "Default", execFail: "", runs: 3, failures: 3},
+		{testName: "Stop", execFail: execFailStop, runs: 1, failures: 1},
+		{testName: "Ignore", execFail: execFailIgnore, runs: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			tempDir, cleanup := createTempDir(t, map[string]int{".log": 3})
+			defer cleanup()
+
+			cfg := config{root: tempDir, exec: `sh -c "echo run; exit 3"`,
+				execFail: tc.execFail, del: true, wLog: &bytes.Buffer{}}
+			err := run(&buffer, cfg)
+			assert.Equal(t, tc.runs, strings.Count(buffer.String(), "run\n"))
+
+			switch {
+			case tc.failures == 0:
+				assert.Nil(t, err)
+			case tc.execFail == execFailStop:
+				assert.True(t, isCommandError(err))
+			default:
+				var errs actionErrors
+				assert.True(t, errors.As(err, &errs))
+				assert.Equal(t, tc.failures, len(errs))
+			}
+
+			// files are only deleted when their command succeeded
+			remaining, err := os.ReadDir(tempDir)
+			assert.Nil(t, err)
+			if tc.execFail == execFailIgnore {
+				assert.Empty(t, remaining)
+			} else {
+				assert.Equal(t, 3, len(remaining))
+			}
+		})
+	}
+}
+
+func TestRunExecDryRun(t *testing.T) {
+	var buffer bytes.Buffer
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 2})
+	defer cleanup()
+
+	marker := filepath.Join(tempDir, "marker")
+	cfg := config{root: tempDir, exec: "touch " + marker, execBatch: "rm",
+		dryRun: true, wLog: &bytes.Buffer{}}
+	assert.Nil(t, run(&buffer, cfg))
+
+	assert.Equal(t, 3, strings.Count(buffer.String(),
+		dryRunPrefix+execLogPrefix))
+
+	_, err := os.Stat(marker)
+	assert.True(t, os.IsNotExist(err))
+	remaining, err := os.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(remaining))
+}
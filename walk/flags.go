@@ -0,0 +1,493 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// stringList is a flag that can be repeated, collecting every value
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ",")
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
+// patternList is a repeatable flag holding glob patterns. Patterns are
+// validated as they are parsed.
+type patternList []string
+
+func (pl *patternList) String() string {
+	return strings.Join(*pl, ",")
+}
+
+func (pl *patternList) Set(value string) error {
+	if _, err := filepath.Match(strings.TrimSuffix(value, "/"), ""); err != nil {
+		return fmt.Errorf("%s: %w", value, err)
+	}
+
+	*pl = append(*pl, value)
+	return nil
+}
+
+// regexpFlag is a flag holding a compiled regular expression
+type regexpFlag struct {
+	re *regexp.Regexp
+}
+
+func (rf *regexpFlag) String() string {
+	if rf.re == nil {
+		return ""
+	}
+
+	return rf.re.String()
+}
+
+func (rf *regexpFlag) Set(value string) error {
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return err
+	}
+
+	rf.re = re
+	return nil
+}
+
+// templateFlag is a flag holding a template for the destination of moved
+// files
+type templateFlag struct {
+	t *template.Template
+}
+
+func (tf *templateFlag) String() string {
+	if tf.t == nil {
+		return ""
+	}
+
+	return tf.t.Root.String()
+}
+
+func (tf *templateFlag) Set(value string) error {
+	t, err := template.New("move").Parse(value)
+	if err != nil {
+		return err
+	}
+
+	// catch the unknown fields now rather than on the first file
+	if err := t.Execute(io.Discard, moveData{}); err != nil {
+		return err
+	}
+
+	tf.t = t
+	return nil
+}
+
+// permission bits, including the special ones
+const permBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// special permission bits in the order of their octal digit
+var specialBits = []fs.FileMode{fs.ModeSetuid, fs.ModeSetgid, fs.ModeSticky}
+
+// parseOctalMode converts octal permissions, e.g. "0755", into a file mode
+func parseOctalMode(digits string) (fs.FileMode, error) {
+	bits, err := strconv.ParseUint(digits, 8, 32)
+	if err != nil || bits > 07777 {
+		return 0, fmt.Errorf("%s: invalid permissions", digits)
+	}
+
+	mode := fs.FileMode(bits) & fs.ModePerm
+	for i, special := range specialBits {
+		if bits&(04000>>i) != 0 {
+			mode |= special
+		}
+	}
+
+	return mode, nil
+}
+
+// formatOctalMode returns the permissions of 'mode' in octal, e.g. "0755"
+func formatOctalMode(mode fs.FileMode) string {
+	bits := uint64(mode.Perm())
+	for i, special := range specialBits {
+		if mode&special != 0 {
+			bits |= 04000 >> i
+		}
+	}
+
+	return fmt.Sprintf("%04o", bits)
+}
+
+// permFlag is a flag holding permission bits in octal, like find's -perm:
+// "644" matches exactly those bits, "-022" all of them and "/022" any of them
+type permFlag struct {
+	mode fs.FileMode
+	// '=', '-' or '/', zero when the flag isn't set
+	op byte
+}
+
+func (pf *permFlag) String() string {
+	if pf.op == 0 {
+		return ""
+	}
+
+	prefix := ""
+	if pf.op != '=' {
+		prefix = string(pf.op)
+	}
+
+	return prefix + formatOctalMode(pf.mode)
+}
+
+func (pf *permFlag) Set(value string) error {
+	op := byte('=')
+	digits := value
+	if len(value) > 0 && (value[0] == '-' || value[0] == '/') {
+		op, digits = value[0], value[1:]
+	}
+
+	mode, err := parseOctalMode(digits)
+	if err != nil {
+		return err
+	}
+
+	pf.mode, pf.op = mode, op
+	return nil
+}
+
+// match reports whether the permissions of 'mode' match the flag
+func (pf permFlag) match(mode fs.FileMode) bool {
+	mode &= permBits
+
+	switch pf.op {
+	case '=':
+		return mode == pf.mode
+	case '-':
+		return mode&pf.mode == pf.mode
+	case '/':
+		return pf.mode == 0 || mode&pf.mode != 0
+	default:
+		return true
+	}
+}
+
+// modeClause is a symbolic change of permissions, e.g. "go-w"
+type modeClause struct {
+	// bits of the users the clause applies to
+	who fs.FileMode
+	// '+', '-' or '='
+	op byte
+	// bits added, removed or set
+	perm fs.FileMode
+}
+
+// permission bits of each class of users, by letter
+var whoBits = map[rune]fs.FileMode{
+	'u': 0700 | fs.ModeSetuid,
+	'g': 0070 | fs.ModeSetgid,
+	'o': 0007 | fs.ModeSticky,
+}
+
+// permission bits of each letter, for all the classes of users
+var permLetterBits = map[rune]fs.FileMode{
+	'r': 0444,
+	'w': 0222,
+	'x': 0111,
+	's': fs.ModeSetuid | fs.ModeSetgid,
+	't': fs.ModeSticky,
+}
+
+// modeFlag is a flag holding a change of permissions, either octal
+// permissions or symbolic clauses as in chmod, e.g. "o-w,g+r"
+type modeFlag struct {
+	value string
+	// octal permissions, used when there are no clauses
+	mode    fs.FileMode
+	clauses []modeClause
+}
+
+func (mf *modeFlag) String() string {
+	return mf.value
+}
+
+func (mf *modeFlag) Set(value string) error {
+	if mode, err := parseOctalMode(value); err == nil {
+		mf.value, mf.mode, mf.clauses = value, mode, nil
+		return nil
+	}
+
+	var clauses []modeClause
+	for _, clause := range strings.Split(value, ",") {
+		i := strings.IndexAny(clause, "+-=")
+		if i < 0 {
+			return fmt.Errorf("%s: invalid mode", value)
+		}
+
+		mc := modeClause{op: clause[i]}
+		for _, r := range clause[:i] {
+			if r == 'a' {
+				mc.who |= permBits
+				continue
+			}
+			bits, ok := whoBits[r]
+			if !ok {
+				return fmt.Errorf("%s: invalid mode", value)
+			}
+			mc.who |= bits
+		}
+		// without users, the clause applies to all of them
+		if mc.who == 0 {
+			mc.who = permBits
+		}
+
+		for _, r := range clause[i+1:] {
+			bits, ok := permLetterBits[r]
+			if !ok {
+				return fmt.Errorf("%s: invalid mode", value)
+			}
+			mc.perm |= bits & mc.who
+		}
+
+		clauses = append(clauses, mc)
+	}
+
+	mf.value, mf.mode, mf.clauses = value, 0, clauses
+	return nil
+}
+
+// apply returns the permissions resulting from changing those of 'mode'
+func (mf modeFlag) apply(mode fs.FileMode) fs.FileMode {
+	mode &= permBits
+	if mf.clauses == nil {
+		return mf.mode
+	}
+
+	for _, mc := range mf.clauses {
+		switch mc.op {
+		case '+':
+			mode |= mc.perm
+		case '-':
+			mode &^= mc.perm
+		case '=':
+			mode = mode&^mc.who | mc.perm
+		}
+	}
+
+	return mode
+}
+
+// idFlag is a flag holding a user or group ID, given as a number or a name
+type idFlag struct {
+	id string
+	// resolves a name into an ID
+	lookup func(name string) (string, error)
+}
+
+func (idf *idFlag) String() string {
+	return idf.id
+}
+
+func (idf *idFlag) Set(value string) error {
+	if _, err := strconv.ParseUint(value, 10, 32); err == nil {
+		idf.id = value
+		return nil
+	}
+
+	id, err := idf.lookup(value)
+	if err != nil {
+		return err
+	}
+
+	idf.id = id
+	return nil
+}
+
+// ownerFlag is a flag holding a new owner and group given as "user:group".
+// Either can be omitted to keep the current one, e.g. "alice" or ":staff".
+type ownerFlag struct {
+	uid string
+	gid string
+}
+
+func (of *ownerFlag) String() string {
+	if of.uid == "" && of.gid == "" {
+		return ""
+	}
+
+	return of.uid + ":" + of.gid
+}
+
+func (of *ownerFlag) Set(value string) error {
+	name, group := value, ""
+	if i := strings.Index(value, ":"); i >= 0 {
+		name, group = value[:i], value[i+1:]
+	}
+
+	if name == "" && group == "" {
+		return fmt.Errorf("%s: invalid owner", value)
+	}
+
+	uid := idFlag{lookup: lookupUser}
+	if name != "" {
+		if err := uid.Set(name); err != nil {
+			return err
+		}
+	}
+
+	gid := idFlag{lookup: lookupGroup}
+	if group != "" {
+		if err := gid.Set(group); err != nil {
+			return err
+		}
+	}
+
+	of.uid, of.gid = uid.id, gid.id
+	return nil
+}
+
+// layouts accepted for absolute dates
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// timeFlag is a flag holding a point in time, given either as an absolute
+// date or as an age relative to when the flag is parsed
+type timeFlag struct {
+	t time.Time
+}
+
+func (tf *timeFlag) String() string {
+	if tf.t.IsZero() {
+		return ""
+	}
+
+	return tf.t.Format(time.RFC3339)
+}
+
+func (tf *timeFlag) Set(value string) error {
+	t, err := parseTime(value, time.Now())
+	if err != nil {
+		return err
+	}
+
+	tf.t = t
+	return nil
+}
+
+// parseTime converts 'value' into a point in time. Ages such as "30d", "2w"
+// or any duration understood by time.ParseDuration are subtracted from 'now'.
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if age, err := parseAge(value); err == nil {
+		return now.Add(-age), nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%s: invalid age or date", value)
+}
+
+// parseAge extends time.ParseDuration with days (d) and weeks (w)
+func parseAge(value string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+
+	if len(value) > 1 {
+		if unit, ok := units[value[len(value)-1]]; ok {
+			n, err := strconv.ParseUint(value[:len(value)-1], 10, 32)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s: negative age", value)
+	}
+
+	return d, nil
+}
+
+// multipliers for the size units. Single letter and IEC units are powers of
+// 1024, SI units are powers of 1000.
+var sizeUnits = map[string]uint64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KIB": 1 << 10,
+	"KB":  1000,
+	"M":   1 << 20,
+	"MIB": 1 << 20,
+	"MB":  1000 * 1000,
+	"G":   1 << 30,
+	"GIB": 1 << 30,
+	"GB":  1000 * 1000 * 1000,
+	"T":   1 << 40,
+	"TIB": 1 << 40,
+	"TB":  1000 * 1000 * 1000 * 1000,
+}
+
+// sizeFlag is a flag holding a size in bytes that accepts human readable
+// units such as 10K, 5MiB or 2G
+type sizeFlag uint64
+
+func (sf *sizeFlag) String() string {
+	return strconv.FormatUint(uint64(*sf), 10)
+}
+
+func (sf *sizeFlag) Set(value string) error {
+	size, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+
+	*sf = sizeFlag(size)
+	return nil
+}
+
+// parseSize converts a size with an optional unit into a number of bytes
+func parseSize(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+
+	i := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(value)
+	}
+
+	unit, ok := sizeUnits[strings.ToUpper(strings.TrimSpace(value[i:]))]
+	if !ok || i == 0 {
+		return 0, fmt.Errorf("%s: invalid size", value)
+	}
+
+	n, err := strconv.ParseFloat(value[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid size", value)
+	}
+
+	return uint64(n * float64(unit)), nil
+}
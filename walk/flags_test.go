@@ -0,0 +1,208 @@
+package main
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2022, 4, 4, 12, 0, 0, 0, time.Local)
+
+	testCases := []struct {
+		testName string
+		value    string
+		expected time.Time
+		fails    bool
+	}{
+		{"Days", "30d", now.AddDate(0, 0, -30), false},
+		{"Weeks", "2w", now.AddDate(0, 0, -14), false},
+		{"Hours", "12h", now.Add(-12 * time.Hour), false},
+		{"CompoundDuration", "1h30m", now.Add(-90 * time.Minute), false},
+		{"Date", "2022-01-02",
+			time.Date(2022, 1, 2, 0, 0, 0, 0, time.Local), false},
+		{"DateTime", "2022-01-02 15:04:05",
+			time.Date(2022, 1, 2, 15, 4, 5, 0, time.Local), false},
+		{"RFC3339", "2022-01-02T15:04:05Z",
+			time.Date(2022, 1, 2, 15, 4, 5, 0, time.UTC), false},
+		{"NegativeAge", "-12h", time.Time{}, true},
+		{"InvalidDays", "xd", time.Time{}, true},
+		{"Invalid", "yesterday", time.Time{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			result, err := parseTime(tc.value, now)
+			if tc.fails {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.True(t, tc.expected.Equal(result),
+				"expected %s, got %s", tc.expected, result)
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		testName string
+		value    string
+		expected uint64
+		fails    bool
+	}{
+		{"Bytes", "512", 512, false},
+		{"BytesUnit", "512B", 512, false},
+		{"Kilo", "10K", 10 * 1024, false},
+		{"KiloLowercase", "10k", 10 * 1024, false},
+		{"Mebi", "5MiB", 5 * 1024 * 1024, false},
+		{"Giga", "2G", 2 * 1024 * 1024 * 1024, false},
+		{"Megabytes", "1MB", 1000 * 1000, false},
+		{"Fraction", "1.5K", 1536, false},
+		{"UnknownUnit", "10X", 0, true},
+		{"NoNumber", "K", 0, true},
+		{"Empty", "", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			result, err := parseSize(tc.value)
+			if tc.fails {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestTemplateFlag(t *testing.T) {
+	var tf templateFlag
+
+	assert.Nil(t, tf.Set("{{.Year}}/{{.Month}}/{{.Name}}"))
+	assert.Equal(t, "{{.Year}}/{{.Month}}/{{.Name}}", tf.String())
+
+	assert.NotNil(t, tf.Set("{{.Year"))
+	assert.NotNil(t, tf.Set("{{.Hour}}/{{.Name}}"))
+}
+
+func TestPermFlag(t *testing.T) {
+	testCases := []struct {
+		testName string
+		value    string
+		mode     fs.FileMode
+		expected bool
+		fails    bool
+	}{
+		{testName: "Exact", value: "644", mode: 0644, expected: true},
+		{testName: "ExactNoMatch", value: "644", mode: 0664},
+		{testName: "All", value: "-022", mode: 0666, expected: true},
+		{testName: "AllNoMatch", value: "-022", mode: 0646},
+		{testName: "Any", value: "/002", mode: 0646, expected: true},
+		{testName: "AnyNoMatch", value: "/022", mode: 0644},
+		{testName: "Setuid", value: "-4000", mode: 0755 | fs.ModeSetuid,
+			expected: true},
+		{testName: "IgnoresType", value: "755", mode: 0755 | fs.ModeDir,
+			expected: true},
+		{testName: "NotOctal", value: "rwx", fails: true},
+		{testName: "TooLarge", value: "17777", fails: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var pf permFlag
+
+			err := pf.Set(tc.value)
+			if tc.fails {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, pf.match(tc.mode))
+
+			// the flag can be parsed back from its value
+			var again permFlag
+			assert.Nil(t, again.Set(pf.String()))
+			assert.Equal(t, pf, again)
+		})
+	}
+}
+
+func TestModeFlag(t *testing.T) {
+	testCases := []struct {
+		testName string
+		value    string
+		mode     fs.FileMode
+		expected fs.FileMode
+		fails    bool
+	}{
+		{testName: "Octal", value: "640", mode: 0777, expected: 0640},
+		{testName: "RemoveOthersWrite", value: "o-w", mode: 0666,
+			expected: 0664},
+		{testName: "AddGroupRead", value: "g+r", mode: 0600, expected: 0640},
+		{testName: "SetUser", value: "u=rw", mode: 0755, expected: 0655},
+		{testName: "AllExecute", value: "+x", mode: 0644, expected: 0755},
+		{testName: "Clauses", value: "go-rwx,u+x", mode: 0664,
+			expected: 0700},
+		{testName: "Setuid", value: "u+s", mode: 0755,
+			expected: 0755 | fs.ModeSetuid},
+		{testName: "KeepsSpecialBits", value: "o-w",
+			mode: 0777 | fs.ModeSticky, expected: 0775 | fs.ModeSticky},
+		{testName: "InvalidWho", value: "x+r", fails: true},
+		{testName: "InvalidPerm", value: "o-q", fails: true},
+		{testName: "NoOperator", value: "rw", fails: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var mf modeFlag
+
+			err := mf.Set(tc.value)
+			if tc.fails {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, mf.apply(tc.mode))
+		})
+	}
+}
+
+func TestOwnerFlag(t *testing.T) {
+	testCases := []struct {
+		value string
+		uid   string
+		gid   string
+		fails bool
+	}{
+		{value: "1000:100", uid: "1000", gid: "100"},
+		{value: "1000", uid: "1000"},
+		{value: ":100", gid: "100"},
+		{value: "1000:", uid: "1000"},
+		{value: ":", fails: true},
+		{value: "no-such-user-walk:", fails: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			var of ownerFlag
+
+			err := of.Set(tc.value)
+			if tc.fails {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.uid, of.uid)
+			assert.Equal(t, tc.gid, of.gid)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// name of the ignore files used by git
+const gitignoreFile = ".gitignore"
+
+// ignoreRule is a single pattern read from a gitignore-syntax file
+type ignoreRule struct {
+	// pattern split on '/', matched against the path relative to 'base'
+	segments []string
+	// re-include the matching files instead of ignoring them
+	negate bool
+	// only match directories
+	dirOnly bool
+	// directory holding the ignore file
+	base string
+}
+
+// parseIgnoreLine parses a line of an ignore file found in 'base'. It
+// returns false for blank lines and comments.
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+
+	if line == "" || line[0] == '#' {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{base: base}
+
+	switch {
+	case line[0] == '!':
+		rule.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\#`), strings.HasPrefix(line, `\!`):
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// patterns with a separator are relative to the ignore file, the others
+	// match at any level below it
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	rule.segments = strings.Split(line, "/")
+	if !anchored {
+		rule.segments = append([]string{"**"}, rule.segments...)
+	}
+
+	return rule, true
+}
+
+// readIgnoreFile returns the rules of the ignore file at 'filename'. A
+// missing file has no rules.
+func readIgnoreFile(filename string) ([]ignoreRule, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	base := filepath.Dir(filename)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreLine(scanner.Text(), base); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+// match reports whether the rule applies to the file at 'path'
+func (r ignoreRule) match(path string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	rel, err := filepath.Rel(r.base, path)
+	if err != nil {
+		return false
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	return matchSegments(r.segments, parts)
+}
+
+// matchSegments matches the path elements against the pattern elements. A
+// '**' element matches any number of path elements.
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+
+	if pattern[0] == "**" {
+		// a trailing '**' matches everything inside, not the directory itself
+		if len(pattern) == 1 {
+			return len(parts) > 0
+		}
+
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+
+	return matchSegments(pattern[1:], parts[1:])
+}
+
+// ignorer applies the rules of the ignore files found while walking. The
+// rules of a directory are those of its parent followed by its own, so that
+// deeper files take precedence.
+type ignorer struct {
+	// names of the ignore files
+	names []string
+	// skip the .git directories
+	skipGit bool
+	// rules that apply to the entries of each directory walked
+	rules map[string][]ignoreRule
+}
+
+// newIgnorer returns an ignorer for the configured ignore files, or nil if
+// there are none.
+func newIgnorer(cfg config) *ignorer {
+	names := cfg.ignoreFiles
+	if cfg.gitignore {
+		names = append(names[:len(names):len(names)], gitignoreFile)
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	return &ignorer{names: names, skipGit: cfg.gitignore,
+		rules: map[string][]ignoreRule{}}
+}
+
+// enter reads the ignore files of the directory 'dir' before its entries are
+// walked.
+func (ig *ignorer) enter(dir string) error {
+	// the paths of the entries are clean, the root may not be
+	dir = filepath.Clean(dir)
+	parent := ig.rules[filepath.Dir(dir)]
+	rules := parent[:len(parent):len(parent)]
+
+	for _, name := range ig.names {
+		own, err := readIgnoreFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		rules = append(rules, own...)
+	}
+
+	ig.rules[dir] = rules
+	return nil
+}
+
+// ignored reports whether the file at 'path' is ignored. The last matching
+// rule wins.
+func (ig *ignorer) ignored(path string, info fs.FileInfo) bool {
+	if ig.skipGit && info.IsDir() && info.Name() == ".git" {
+		return true
+	}
+
+	rules := ig.rules[filepath.Dir(path)]
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].match(path, info.IsDir()) {
+			return !rules[i].negate
+		}
+	}
+
+	return false
+}
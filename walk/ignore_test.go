@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnoreRuleMatch(t *testing.T) {
+	testCases := []struct {
+		testName string
+		line     string
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{testName: "Name", line: "*.o", path: "src/main.o", expected: true},
+		{testName: "NameNoMatch", line: "*.o", path: "src/main.c"},
+		{testName: "DirOnly", line: "build/", path: "a/build", isDir: true,
+			expected: true},
+		{testName: "DirOnlyFile", line: "build/", path: "a/build"},
+		{testName: "Anchored", line: "/out", path: "out", expected: true},
+		{testName: "AnchoredDeeper", line: "/out", path: "a/out"},
+		{testName: "Relative", line: "doc/*.txt", path: "doc/a.txt",
+			expected: true},
+		{testName: "RelativeDeeper", line: "doc/*.txt", path: "doc/x/a.txt"},
+		{testName: "LeadingStars", line: "**/logs", path: "a/b/logs",
+			expected: true},
+		{testName: "TrailingStars", line: "dist/**", path: "dist/a/b.js",
+			expected: true},
+		{testName: "TrailingStarsDir", line: "dist/**", path: "dist",
+			isDir: true},
+		{testName: "MiddleStars", line: "a/**/b", path: "a/x/y/b",
+			expected: true},
+		{testName: "MiddleStarsNone", line: "a/**/b", path: "a/b",
+			expected: true},
+		{testName: "EscapedHash", line: `\#notes`, path: "#notes",
+			expected: true},
+		{testName: "TrailingSpaces", line: "tmp   ", path: "tmp",
+			expected: true},
+	}
+
+	base := filepath.FromSlash("/repo")
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			rule, ok := parseIgnoreLine(tc.line, base)
+			assert.True(t, ok)
+
+			path := filepath.Join(base, filepath.FromSlash(tc.path))
+			assert.Equal(t, tc.expected, rule.match(path, tc.isDir))
+		})
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		_, ok := parseIgnoreLine(line, base)
+		assert.False(t, ok, line)
+	}
+
+	rule, ok := parseIgnoreLine("!keep.log", base)
+	assert.True(t, ok)
+	assert.True(t, rule.negate)
+}
+
+func TestRunIgnoreFiles(t *testing.T) {
+	files := map[string]string{
+		".gitignore":             "*.log\nbuild/\n!important.log\n",
+		"a.log":                  "",
+		"important.log":          "",
+		"main.go":                "",
+		"build/out.bin":          "",
+		"sub/.gitignore":         "*.go\n!keep.go\n/local/\n",
+		"sub/b.log":              "",
+		"sub/keep.go":            "",
+		"sub/gen.go":             "",
+		"sub/local/x.txt":        "",
+		"sub/deep/local/y.txt":   "",
+		"sub/.walkignore":        "*.txt\n",
+		".git/config":            "",
+		"other/important.log":    "",
+		"other/.gitignore":       "important.log\n",
+		"other/nested/readme.md": "",
+	}
+
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	testCases := []struct {
+		testName string
+		cfg      config
+		expected []string
+	}{
+		{testName: "Gitignore", cfg: config{gitignore: true},
+			expected: []string{".gitignore", "important.log", "main.go",
+				"other/.gitignore", "other/nested/readme.md",
+				"sub/.gitignore", "sub/.walkignore", "sub/deep/local/y.txt",
+				"sub/keep.go"}},
+		{testName: "IgnoreFiles", cfg: config{ignoreFiles: []string{
+			".gitignore", ".walkignore"}},
+			expected: []string{".git/config", ".gitignore", "important.log",
+				"main.go", "other/.gitignore", "other/nested/readme.md",
+				"sub/.gitignore", "sub/.walkignore", "sub/keep.go"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			tc.cfg.root = tempDir
+			tc.cfg.list = true
+			assert.Nil(t, run(&buffer, tc.cfg))
+
+			expected := ""
+			for _, name := range tc.expected {
+				expected += filepath.Join(tempDir, filepath.FromSlash(name)) +
+					"\n"
+			}
+			assert.Equal(t, expected, buffer.String())
+		})
+	}
+}
@@ -8,60 +8,565 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 func main() {
 	root := flag.String("root", ".", "Root directory to start")
+	configFile := flag.String("config", "", "YAML file defining named "+
+		"cleanup profiles")
+	profile := flag.String("profile", "", "Profile to run from the config "+
+		"file. By default, all the profiles are run")
 	logFile := flag.String("log", "", `Log deletes to this file. By default,`+
 		`it will be sent to STDOUT`)
 	// Action options
 	list := flag.Bool("list", false, "List files only")
 	del := flag.Bool("del", false, "Delete files")
+	archive := flag.String("archive", "", "Archive directory")
+	trash := flag.Bool("trash", false, "Move files to the trash directory")
+	safeDel := flag.Bool("safe-del", false, "Make -del move files to the "+
+		"trash directory instead of removing them")
+	move := flag.String("move", "", "Move files into this directory")
+	var moveTemplate templateFlag
+	flag.Var(&moveTemplate, "move-template", "Template for the path of the "+
+		"moved files, e.g. {{.Year}}/{{.Month}}/{{.Name}}. Fields: Name, "+
+		"Base, Ext, Dir, Rel, Year, Month and Day. By default, files keep "+
+		"their path relative to the root directory")
+	collision := flag.String("collision", collisionSkip, "What to do when "+
+		"the destination of a moved file exists: skip, overwrite or suffix")
+	sync := flag.String("sync", "", "Copy files to this directory, unless "+
+		"their copy has the same size, modification time and permissions")
+	syncDelete := flag.Bool("sync-delete", false, "Delete the files in the "+
+		"-sync directory that match the filters but weren't copied")
+	var chmod modeFlag
+	flag.Var(&chmod, "chmod", "Change the permissions of files, given in "+
+		"octal (640) or as in chmod (o-w,g+r)")
+	var chown ownerFlag
+	flag.Var(&chown, "chown", "Change the owner and group of files, given as "+
+		"user:group, user or :group")
+	trashDir := flag.String("trash-dir", defaultTrashDir(), "Trash directory")
+	purgeTrash := flag.Bool("empty-trash", false, "Permanently remove the "+
+		"files in the trash directory. Use with -older-than to only remove "+
+		"files trashed before that time")
+	dupes := flag.Bool("dupes", false, "Report sets of files with the same "+
+		"contents")
+	dupesAction := flag.String("dupes-action", dupesReport, "What to do with "+
+		"the duplicates of the kept file: report, delete or hardlink")
+	keepLast := flag.Int("keep-last", 0, "Leave alone the newest this many "+
+		"matched files of every directory, e.g. to keep the last 7 backups")
+	keep := flag.String("keep", keepOldest, "Which file of a duplicate set "+
+		"to keep: oldest, newest or shortest (path)")
+	execCmd := flag.String("exec", "", `Run this command for every file, `+
+		`replacing {} with its path (e.g. "gzip -9 {}"). The path is `+
+		`appended when there's no {}`)
+	execBatch := flag.String("exec-batch", "", "Run this command for "+
+		"batches of files, like xargs, replacing an argument {} with their "+
+		"paths. The paths are appended when there's no {}")
+	execFail := flag.String("exec-fail", execFailContinue, "What to do when "+
+		"a command fails: continue (and exit with an error), stop or ignore")
+	manifest := flag.String("manifest", "", "Write the path, size, SHA-256 "+
+		"and modification time of the files to this CSV manifest")
+	checkManifest := flag.String("verify", "", "Report the files added, "+
+		"removed or modified since this manifest was written")
+	compare := flag.String("compare", "", "Report the files only found in "+
+		"the root directory or this one, and the ones that differ in size or "+
+		"modification time")
+	compareContent := flag.Bool("compare-content", false, "Compare the "+
+		"contents of the files instead of their modification times with "+
+		"-compare")
+	du := flag.Bool("du", false, "Report the size of the matched files in "+
+		"every directory, largest first")
+	duDepth := flag.Int("du-depth", 1, "Report the directories up to this "+
+		"many levels below the root directory with -du")
+	watch := flag.Bool("watch", false, "Keep running after the walk, applying "+
+		"the action to the files that appear or change under the root "+
+		"directory until interrupted")
+	watchInterval := flag.Duration("watch-interval", 2*time.Second, "How "+
+		"often -watch scans for changes. Files are only processed once they "+
+		"haven't been modified for this long")
+	restore := flag.String("restore", "", "Restore files listed in this "+
+		"delete log from the archive or trash directory")
+	dryRun := flag.Bool("dry-run", false, "Print the actions that would be "+
+		"taken without changing any files")
+	workers := flag.Int("workers", 1, "Number of files to process concurrently")
+	format := flag.String("format", formatText, "Output format: text, json, "+
+		"csv or ndjson")
+	showSummary := flag.Bool("summary", false, "Print a summary at the end "+
+		"of the run. Always included in the json, csv and ndjson formats")
+	// Traversal options
+	maxDepth := flag.Int("maxdepth", 0, "Descend at most this many levels "+
+		"below the root directory. By default, there's no limit")
+	minDepth := flag.Int("mindepth", 0, "Ignore files less than this many "+
+		"levels below the root directory")
+	xdev := flag.Bool("xdev", false, "Don't descend into directories on "+
+		"other file systems than the root directory")
+	symlinks := flag.String("symlinks", symlinksFile, "How to handle "+
+		"symbolic links: file (match the links themselves without following "+
+		"them), skip, follow or only (match links only)")
+	gitignore := flag.Bool("gitignore", false, "Skip the files ignored by the "+
+		".gitignore files found while descending, and the .git directories")
 	// Filter options
-	ext := flag.String("ext", "", "File extension to filter out")
-	minSize := flag.Uint64("minSize", 0, "Minimum file size")
+	var (
+		ignoreFiles stringList
+		ext         stringList
+		include     patternList
+		exclude     patternList
+		match       regexpFlag
+	)
+	flag.Var(&ignoreFiles, "ignore-file", "Name of gitignore-syntax files "+
+		"whose rules apply to the directory they're found in and below. Can "+
+		"be repeated")
+	flag.Var(&ext, "ext", "File extension to filter out. Can be repeated")
+	flag.Var(&include, "include", "Only match files whose name or relative "+
+		"path matches this glob. Can be repeated")
+	flag.Var(&exclude, "exclude", "Skip files and directories whose name or "+
+		"relative path matches this glob. Can be repeated")
+	flag.Var(&match, "match", "Only match files whose path matches this "+
+		"regular expression")
+	var minSize, maxSize sizeFlag
+	flag.Var(&minSize, "minSize", "Minimum file size. Accepts units such as "+
+		"10K, 5MiB, 2G or 1MB")
+	flag.Var(&maxSize, "maxSize", "Maximum file size. Accepts units such as "+
+		"10K, 5MiB, 2G or 1MB")
+	fileTypes := flag.String("type", "", "Only match files of these types: f "+
+		"(regular file), d (directory), l (symbolic link), p (named pipe) or "+
+		"s (socket). Several types can be given, e.g. f,l")
+	executable := flag.Bool("executable", false, "Only match files with an "+
+		"execute permission bit set")
+	var perm permFlag
+	flag.Var(&perm, "perm", "Only match files with these octal permissions: "+
+		"exactly (644), all of them (-022) or any of them (/022)")
+	uid := idFlag{lookup: lookupUser}
+	flag.Var(&uid, "user", "Only match files owned by this user name or ID")
+	gid := idFlag{lookup: lookupGroup}
+	flag.Var(&gid, "group", "Only match files owned by this group name or ID")
+	noUser := flag.Bool("nouser", false, "Only match files whose owner "+
+		"doesn't exist")
+	noGroup := flag.Bool("nogroup", false, "Only match files whose group "+
+		"doesn't exist")
+	var olderThan, newerThan timeFlag
+	flag.Var(&olderThan, "older-than", "Only match files modified before this "+
+		"date or longer ago than this age (e.g. 30d, 12h, 2006-01-02)")
+	flag.Var(&newerThan, "newer-than", "Only match files modified after this "+
+		"date or more recently than this age (e.g. 30d, 12h, 2006-01-02)")
 	flag.Parse()
 
 	cfg := config{
-		root:    *root,
-		list:    *list,
-		del:     *del,
-		ext:     *ext,
-		minSize: *minSize,
+		root:           *root,
+		list:           *list,
+		del:            *del,
+		archive:        *archive,
+		trash:          *trash,
+		safeDel:        *safeDel,
+		trashDir:       *trashDir,
+		move:           *move,
+		moveTemplate:   moveTemplate.t,
+		collision:      *collision,
+		sync:           *sync,
+		syncDelete:     *syncDelete,
+		chmod:          chmod,
+		chown:          chown,
+		exec:           *execCmd,
+		execBatch:      *execBatch,
+		execFail:       *execFail,
+		emptyTrash:     *purgeTrash,
+		restore:        *restore,
+		dupes:          *dupes,
+		dupesAction:    *dupesAction,
+		manifest:       *manifest,
+		checkManifest:  *checkManifest,
+		compare:        *compare,
+		compareContent: *compareContent,
+		du:             *du,
+		duDepth:        *duDepth,
+		watch:          *watch,
+		watchInterval:  *watchInterval,
+		keep:           *keep,
+		keepLast:       *keepLast,
+		dryRun:         *dryRun,
+		workers:        *workers,
+		format:         *format,
+		summary:        *showSummary,
+		maxDepth:       *maxDepth,
+		minDepth:       *minDepth,
+		xdev:           *xdev,
+		symlinks:       *symlinks,
+		ignoreFiles:    ignoreFiles,
+		gitignore:      *gitignore,
+		ext:            ext,
+		include:        include,
+		exclude:        exclude,
+		match:          match.re,
+		types:          *fileTypes,
+		executable:     *executable,
+		perm:           perm,
+		uid:            uid.id,
+		gid:            gid.id,
+		noUser:         *noUser,
+		noGroup:        *noGroup,
+		minSize:        uint64(minSize),
+		maxSize:        uint64(maxSize),
+		olderThan:      olderThan.t,
+		newerThan:      newerThan.t,
 	}
+
+	if *configFile != "" {
+		profiles, err := loadProfiles(*configFile)
+		exit(err)
+		exit(runProfiles(os.Stdout, cfg, *logFile, profiles, *profile))
+		return
+	}
+
+	exit(execute(os.Stdout, cfg, *logFile))
+}
+
+// execute configures and verifies the options before running the selected
+// mode.
+func execute(out io.Writer, cfg config, logFile string) error {
 	//configure the options
-	exit(cfg.configure(*logFile))
+	if err := cfg.configure(logFile); err != nil {
+		return err
+	}
+	if logFile != "" {
+		defer cfg.wLog.(*os.File).Close()
+	}
 	// verify the options
-	exit(cfg.verify())
+	if err := cfg.verify(); err != nil {
+		return err
+	}
+
+	// the newest files must be known before any file is processed, the
+	// watch finds them again on every scan
+	if cfg.keepLast > 0 && !cfg.watch {
+		kept, err := newestFiles(cfg)
+		if err != nil {
+			return err
+		}
+		cfg.kept = kept
+	}
 
 	// run the program
-	exit(run(os.Stdout, cfg))
+	switch {
+	case cfg.restore != "":
+		return restoreFiles(out, cfg)
+	case cfg.emptyTrash:
+		return emptyTrash(out, cfg)
+	case cfg.dupes:
+		return findDupes(out, cfg)
+	case cfg.du:
+		return diskUsage(out, cfg)
+	case cfg.compare != "":
+		return compareTrees(out, cfg)
+	case cfg.manifest != "":
+		return writeManifest(out, cfg)
+	case cfg.checkManifest != "":
+		return verifyManifest(out, cfg)
+	case cfg.watch:
+		return watchFiles(out, cfg, interrupted())
+	default:
+		return run(out, cfg)
+	}
 }
 
 func run(out io.Writer, cfg config) error {
-	delLogger := log.New(cfg.wLog, "DELETED FILE: ", log.LstdFlags)
+	rep := newReporter(out, cfg)
+	sum := newSummary(cfg.dryRun)
+
+	if cfg.exec != "" || cfg.execBatch != "" {
+		runner, err := newExecutor(cfg)
+		if err != nil {
+			return err
+		}
+		cfg.runner = runner
+	}
+	cmdOut := commandOutput(out, cfg)
+	batchLog := newLogger(out, cfg, execLogPrefix)
+
+	// relative paths of the files synced, or already in sync
+	synced := map[string]bool{}
+
+	// records are always emitted from a single goroutine in walk order
+	emit := func(r fileRecord) error {
+		sum.add(r)
+		if err := rep.report(r); err != nil {
+			return err
+		}
+
+		if cfg.syncDelete {
+			synced[relPath(cfg.root, r.Path)] = true
+		}
+
+		if cfg.execBatch != "" {
+			return cfg.runner.add(r.Path, cmdOut, batchLog)
+		}
+		return nil
+	}
+
+	// a file that fails doesn't end the run, as with several workers, unless
+	// a command failed and the policy says so
+	var failed actionErrors
+	tolerate := func(err error) error {
+		if err == nil || isCommandError(err) && cfg.execFail == execFailStop {
+			return err
+		}
+		failed = append(failed, err)
+		return nil
+	}
+
+	var err error
+	if cfg.workers > 1 {
+		err = runWorkers(out, cfg, sum, emit)
+	} else {
+		logs := newLoggers(out, cfg)
+		err = walkFiles(cfg, sum, func(path string, info fs.FileInfo) error {
+			action, err := processFile(path, info, out, cfg, logs)
+			if err == nil {
+				err = emit(newRecord(path, info, action))
+			}
+
+			return tolerate(err)
+		})
+	}
+
+	// only prune when every file was synced, a file that failed would be lost
+	if cfg.syncDelete && err == nil && len(failed) == 0 {
+		err = pruneSync(cfg, synced, newLogger(out, cfg, pruneLogPrefix))
+	}
+
+	// run the command on the last batch, unless the run was stopped
+	if cfg.execBatch != "" && !cfg.runner.stopped() {
+		if batchErr := cfg.runner.flush(cmdOut, batchLog); batchErr != nil {
+			failed = append(failed, batchErr)
+		}
+	}
+
+	if len(failed) > 0 {
+		if err != nil {
+			failed = append(failed, err)
+		}
+		err = failed
+		// a single error is returned as is, as with several workers
+		if len(failed) == 1 {
+			err = failed[0]
+		}
+	}
+
+	if err != nil {
+		sum.addError(err)
+	}
+
+	// the summary completes structured output, even when the walk failed
+	if sumErr := rep.summarize(*sum); err == nil {
+		err = sumErr
+	}
+
+	return err
+}
+
+// walkFiles walks the tree under 'cfg.root' calling 'fn' for every file that
+// isn't filtered out. When 'sum' isn't nil, it counts the files and
+// directories visited.
+func walkFiles(cfg config, sum *summary,
+	fn func(path string, info fs.FileInfo) error) error {
+	var (
+		rootDev uint64
+		hasDev  bool
+	)
+	if cfg.xdev {
+		info, err := os.Stat(cfg.root)
+		if err != nil {
+			return err
+		}
+		rootDev, hasDev = deviceID(info)
+	}
 
-	err := filepath.Walk(cfg.root,
+	follow := cfg.symlinks == symlinksFollow
+	ig := newIgnorer(cfg)
+	cfg.outputDirs = outputDirs(cfg)
+
+	return walkTree(cfg.root, follow,
 		func(path string, info fs.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
 
-			if filterOut(info, cfg) {
-				return nil
+			if skipDir(path, info, cfg) {
+				return filepath.SkipDir
 			}
 
-			switch {
-			case cfg.list:
-				return listFile(path, out)
-			case cfg.del:
-				return deleteFile(path, delLogger)
-			default:
+			if ig != nil && path != cfg.root && ig.ignored(path, info) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
 				return nil
 			}
+
+			// don't descend into other file systems, like mount points
+			if hasDev && info.IsDir() {
+				if dev, ok := deviceID(info); ok && dev != rootDev {
+					return filepath.SkipDir
+				}
+			}
+
+			if sum != nil {
+				sum.visit(info)
+			}
+
+			// the directory is matched, but none of its contents
+			lastDepth := info.IsDir() && path != cfg.root &&
+				cfg.maxDepth > 0 && pathDepth(cfg.root, path) >= cfg.maxDepth
+
+			// the rules of the directory apply to everything below it
+			if ig != nil && info.IsDir() && !lastDepth {
+				if err := ig.enter(path); err != nil {
+					return err
+				}
+			}
+
+			if !filterOut(path, info, cfg) {
+				if err := fn(path, info); err != nil {
+					return err
+				}
+			}
+
+			if lastDepth {
+				return filepath.SkipDir
+			}
+			return nil
 		})
+}
 
-	return err
+// processFile applies the configured actions to a single matched file,
+// returning the actions taken.
+func processFile(path string, info fs.FileInfo, out io.Writer, cfg config,
+	logs loggers) (string, error) {
+	if cfg.list {
+		return actionList, nil
+	}
+
+	actions := []string{}
+
+	// run the command first, so that it sees the file where it was found
+	if cfg.exec != "" {
+		if err := cfg.runner.runFile(path, commandOutput(out, cfg),
+			logs.exec); err != nil {
+			return "", err
+		}
+	}
+	if cfg.exec != "" || cfg.execBatch != "" {
+		actions = append(actions, actionExec)
+	}
+
+	if cfg.chmod.value != "" {
+		changed, err := changeMode(path, info, cfg.chmod, logs.chmod,
+			cfg.dryRun)
+		if err != nil {
+			return "", err
+		}
+		if changed {
+			actions = append(actions, actionChmod)
+		}
+	}
+
+	if cfg.chown.uid != "" || cfg.chown.gid != "" {
+		changed, err := changeOwner(path, info, cfg.chown, logs.chown,
+			cfg.dryRun)
+		if err != nil {
+			return "", err
+		}
+		if changed {
+			actions = append(actions, actionChown)
+		}
+	}
+
+	if cfg.sync != "" {
+		synced, err := syncFile(path, info, cfg, logs.sync)
+		if err != nil {
+			return "", err
+		}
+		if synced {
+			actions = append(actions, actionSync)
+		}
+	}
+
+	// archive before deleting so a recoverable copy always exists
+	if cfg.archive != "" {
+		archived, err := archiveFile(cfg.archive, cfg.root, path, info,
+			cfg.dryRun)
+		if err != nil {
+			return "", err
+		}
+		// the archive is recorded so the file can be restored from anywhere
+		logs.arch.Printf("%s -> %s", absPath(path), absPath(archived))
+		actions = append(actions, actionArchive)
+	}
+
+	switch {
+	case cfg.trash || (cfg.del && cfg.safeDel):
+		if err := trashFile(cfg.trashDir, path, logs.trash, cfg.dryRun); err != nil {
+			return "", err
+		}
+		actions = append(actions, actionTrash)
+	case cfg.del:
+		if err := deleteFile(path, logs.del, cfg.dryRun); err != nil {
+			return "", err
+		}
+		actions = append(actions, actionDelete)
+	case cfg.move != "":
+		moved, err := relocateFile(path, info, cfg, logs.move)
+		if err != nil {
+			return "", err
+		}
+		if moved {
+			actions = append(actions, actionMove)
+		}
+	}
+
+	return strings.Join(actions, "+"), nil
+}
+
+// loggers groups the loggers used to record the actions taken on files
+type loggers struct {
+	del   *log.Logger
+	arch  *log.Logger
+	trash *log.Logger
+	link  *log.Logger
+	exec  *log.Logger
+	move  *log.Logger
+	chmod *log.Logger
+	chown *log.Logger
+	sync  *log.Logger
+	dupe  *log.Logger
+}
+
+func newLoggers(out io.Writer, cfg config) loggers {
+	return loggers{
+		del:   newLogger(out, cfg, delLogPrefix),
+		arch:  newLogger(out, cfg, archLogPrefix),
+		trash: newLogger(out, cfg, trashLogPrefix),
+		link:  newLogger(out, cfg, linkLogPrefix),
+		exec:  newLogger(out, cfg, execLogPrefix),
+		move:  newLogger(out, cfg, moveLogPrefix),
+		chmod: newLogger(out, cfg, chmodLogPrefix),
+		chown: newLogger(out, cfg, chownLogPrefix),
+		sync:  newLogger(out, cfg, syncLogPrefix),
+		dupe:  newLogger(out, cfg, dupeLogPrefix),
+	}
+}
+
+// newLogger creates a logger for an action. In dry-run mode the messages are
+// sent to 'out' instead of the log so that they are always visible, unless
+// 'out' is used for structured output.
+func newLogger(out io.Writer, cfg config, prefix string) *log.Logger {
+	if cfg.dryRun {
+		if cfg.format != "" && cfg.format != formatText {
+			out = cfg.wLog
+		}
+		return log.New(out, dryRunPrefix+prefix, log.LstdFlags)
+	}
+
+	return log.New(cfg.wLog, prefix, log.LstdFlags)
 }
 
 func exit(err error) {
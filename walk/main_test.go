@@ -2,11 +2,15 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -20,13 +24,38 @@ func TestRun(t *testing.T) {
 		{testName: "NoFilter", cfg: config{root: "testdata", list: true},
 			expected: "testdata/dir.log\ntestdata/dir2/script.sh\n"},
 		{testName: "FilterExtensionMatch", cfg: config{root: "testdata",
-			ext: ".log", list: true}, expected: "testdata/dir.log\n"},
+			ext: []string{".log"}, list: true}, expected: "testdata/dir.log\n"},
 		{testName: "FilterExtensionSizeMatch", cfg: config{root: "testdata",
-			ext: "log", minSize: 10, list: true}, expected: "testdata/dir.log\n"},
+			ext: []string{"log"}, minSize: 10, list: true},
+			expected: "testdata/dir.log\n"},
 		{testName: "FilterExtensionSizeNoMatch", cfg: config{root: "testdata",
-			ext: "log", minSize: 20, list: true}, expected: ""},
+			ext: []string{"log"}, minSize: 20, list: true}, expected: ""},
 		{testName: "FilterExtensionNoMatch", cfg: config{root: "testdata",
-			ext: ".gz", minSize: 0, list: true}, expected: ""},
+			ext: []string{".gz"}, minSize: 0, list: true}, expected: ""},
+		{testName: "FilterMaxSizeMatch", cfg: config{root: "testdata",
+			minSize: 10, maxSize: 20, list: true},
+			expected: "testdata/dir.log\n"},
+		{testName: "FilterMaxSizeNoMatch", cfg: config{root: "testdata",
+			minSize: 1, maxSize: 10, list: true}, expected: ""},
+		{testName: "MaxDepth", cfg: config{root: "testdata", maxDepth: 1,
+			list: true}, expected: "testdata/dir.log\n"},
+		{testName: "MaxDepthDirectories", cfg: config{root: "testdata",
+			maxDepth: 1, types: "d", list: true},
+			expected: "testdata\ntestdata/dir2\n"},
+		{testName: "MinDepth", cfg: config{root: "testdata", minDepth: 2,
+			list: true}, expected: "testdata/dir2/script.sh\n"},
+		{testName: "SameFileSystem", cfg: config{root: "testdata", xdev: true,
+			list: true},
+			expected: "testdata/dir.log\ntestdata/dir2/script.sh\n"},
+		{testName: "FilterMultipleExtensions", cfg: config{root: "testdata",
+			ext: []string{".log", "sh"}, list: true},
+			expected: "testdata/dir.log\ntestdata/dir2/script.sh\n"},
+		{testName: "FilterExcludeDirectory", cfg: config{root: "testdata",
+			exclude: []string{"dir2/"}, list: true},
+			expected: "testdata/dir.log\n"},
+		{testName: "FilterIncludeExclude", cfg: config{root: "testdata",
+			include: []string{"*.log", "*.sh"}, exclude: []string{"*.sh"},
+			list: true}, expected: "testdata/dir.log\n"},
 	}
 
 	for _, tc := range testCases {
@@ -54,7 +83,7 @@ func TestRunDelExtension(t *testing.T) {
 	}{
 		{
 			testName: "DeleteExtensionNoMatch",
-			cfg:      config{ext: ".log", del: true},
+			cfg:      config{ext: []string{".log"}, del: true},
 			filesToCreate: map[string]int{
 				".gz": 10,
 			},
@@ -66,7 +95,7 @@ func TestRunDelExtension(t *testing.T) {
 		},
 		{
 			testName: "DeleteExtensionMatch",
-			cfg:      config{ext: ".log", del: true},
+			cfg:      config{ext: []string{".log"}, del: true},
 			filesToCreate: map[string]int{
 				".log": 10,
 			},
@@ -76,7 +105,7 @@ func TestRunDelExtension(t *testing.T) {
 		},
 		{
 			testName: "DeleteExtensionMixed",
-			cfg:      config{ext: ".log", del: true},
+			cfg:      config{ext: []string{".log"}, del: true},
 			filesToCreate: map[string]int{
 				".gz":  5,
 				".log": 5,
@@ -127,6 +156,242 @@ func TestRunDelExtension(t *testing.T) {
 	}
 }
 
+func TestRunArchive(t *testing.T) {
+	testCases := []struct {
+		testName     string
+		cfg          config
+		extNoArchive string
+		nArchive     int
+		nNoArchive   int
+	}{
+		{testName: "ArchiveExtensionNoMatch", extNoArchive: ".gz",
+			cfg: config{ext: []string{".log"}}, nArchive: 0, nNoArchive: 10},
+		{testName: "ArchiveExtensionMatch", extNoArchive: "",
+			cfg: config{ext: []string{".log"}}, nArchive: 10, nNoArchive: 0},
+		{testName: "ArchiveExtensionMixed", extNoArchive: ".gz",
+			cfg: config{ext: []string{".log"}}, nArchive: 5, nNoArchive: 5},
+		{testName: "ArchiveAndDelete", extNoArchive: ".gz", nArchive: 5,
+			cfg: config{ext: []string{".log"}, del: true}, nNoArchive: 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var (
+				buffer    bytes.Buffer
+				logBuffer bytes.Buffer
+			)
+
+			tempDir, cleanup := createTempDir(t, map[string]int{
+				tc.cfg.ext[0]:   tc.nArchive,
+				tc.extNoArchive: tc.nNoArchive,
+			})
+			defer cleanup()
+
+			archiveDir, cleanupArchive := createTempDir(t, nil)
+			defer cleanupArchive()
+
+			tc.cfg.root = tempDir
+			tc.cfg.archive = archiveDir
+			tc.cfg.wLog = &logBuffer
+			err := run(&buffer, tc.cfg)
+			assert.Nil(t, err)
+
+			pattern := filepath.Join(tempDir, fmt.Sprintf("*%s", tc.cfg.ext[0]))
+			expFiles, err := filepath.Glob(pattern)
+			assert.Nil(t, err)
+
+			archived, err := os.ReadDir(archiveDir)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.nArchive, len(archived))
+
+			for _, file := range archived {
+				assert.Equal(t, ".gz", filepath.Ext(file.Name()))
+			}
+
+			// archived files are only deleted when 'del' is set
+			if tc.cfg.del {
+				assert.Empty(t, expFiles)
+			} else {
+				assert.Equal(t, tc.nArchive, len(expFiles))
+			}
+		})
+	}
+}
+
+func TestRunArchiveInsideRoot(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 2})
+	defer cleanup()
+
+	archiveDir := filepath.Join(tempDir, "archive")
+	assert.Nil(t, os.Mkdir(archiveDir, 0755))
+
+	var buffer, logBuffer bytes.Buffer
+	cfg := config{root: tempDir, archive: archiveDir, del: true,
+		wLog: &logBuffer}
+
+	// the archives are never archived again, nor deleted
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, run(&buffer, cfg))
+	}
+
+	archived, err := os.ReadDir(archiveDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(archived))
+	for _, file := range archived {
+		assert.True(t, strings.HasSuffix(file.Name(), ".log.gz"), file.Name())
+	}
+	assert.Equal(t, 2, strings.Count(logBuffer.String(), archLogPrefix))
+}
+
+func TestRunArchiveAgain(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	archiveDir, cleanupArchive := createTempDir(t, nil)
+	defer cleanupArchive()
+
+	var buffer, logBuffer bytes.Buffer
+	cfg := config{root: tempDir, archive: archiveDir, del: true,
+		wLog: &logBuffer}
+
+	// a file archived again, e.g. after a log rotation, keeps both copies
+	path := filepath.Join(tempDir, "app.log")
+	for _, content := range []string{"first", "second"} {
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+		assert.Nil(t, run(&buffer, cfg))
+	}
+
+	for name, content := range map[string]string{"app.log.gz": "first",
+		"app.log-1.gz": "second"} {
+		archived := filepath.Join(archiveDir, name)
+		assert.Equal(t, content, readArchive(t, archived), name)
+		assert.Contains(t, logBuffer.String(), " -> "+archived+"\n")
+	}
+}
+
+func TestRunArchiveFailure(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	archiveDir, cleanupArchive := createTempDir(t, nil)
+	defer cleanupArchive()
+
+	// the file can't be read, as the target of the link is missing
+	assert.Nil(t, os.Symlink("missing", filepath.Join(tempDir, "broken")))
+
+	var buffer, logBuffer bytes.Buffer
+	cfg := config{root: tempDir, archive: archiveDir, del: true,
+		wLog: &logBuffer}
+	assert.NotNil(t, run(&buffer, cfg))
+
+	// no empty or partial archive is left behind
+	archived, err := os.ReadDir(archiveDir)
+	assert.Nil(t, err)
+	assert.Empty(t, archived)
+	_, err = os.Lstat(filepath.Join(tempDir, "broken"))
+	assert.Nil(t, err)
+}
+
+// readArchive returns the decompressed contents of a gzip file
+func readArchive(t *testing.T, path string) string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	assert.Nil(t, err)
+	data, err := io.ReadAll(zr)
+	assert.Nil(t, err)
+
+	return string(data)
+}
+
+func TestRunDryRun(t *testing.T) {
+	var (
+		buffer    bytes.Buffer
+		logBuffer bytes.Buffer
+	)
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 5, ".gz": 5})
+	defer cleanup()
+
+	archiveDir, cleanupArchive := createTempDir(t, nil)
+	defer cleanupArchive()
+
+	cfg := config{root: tempDir, ext: []string{".log"}, archive: archiveDir,
+		del: true, dryRun: true, wLog: &logBuffer}
+	err := run(&buffer, cfg)
+	assert.Nil(t, err)
+
+	// nothing is touched on disk
+	files, err := os.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(files))
+
+	archived, err := os.ReadDir(archiveDir)
+	assert.Nil(t, err)
+	assert.Empty(t, archived)
+
+	// every action is reported on the output instead of the log
+	result := buffer.String()
+	assert.Equal(t, 5, strings.Count(result, dryRunPrefix+archLogPrefix))
+	assert.Equal(t, 5, strings.Count(result, dryRunPrefix+delLogPrefix))
+	assert.Empty(t, logBuffer.String())
+}
+
+func TestRunAge(t *testing.T) {
+	now := time.Now()
+
+	testCases := []struct {
+		testName string
+		cfg      config
+		expected []string
+	}{
+		{testName: "NoAgeFilter", cfg: config{list: true},
+			expected: []string{"new.log", "old.log", "older.log"}},
+		{testName: "OlderThan", cfg: config{list: true,
+			olderThan: now.AddDate(0, 0, -7)},
+			expected: []string{"old.log", "older.log"}},
+		{testName: "NewerThan", cfg: config{list: true,
+			newerThan: now.AddDate(0, 0, -7)},
+			expected: []string{"new.log"}},
+		{testName: "AgeWindow", cfg: config{list: true,
+			olderThan: now.AddDate(0, 0, -7),
+			newerThan: now.AddDate(0, 0, -60)},
+			expected: []string{"old.log"}},
+	}
+
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	// file name and age in days
+	ages := map[string]int{"new.log": 1, "old.log": 30, "older.log": 90}
+	for name, days := range ages {
+		path := filepath.Join(tempDir, name)
+		assert.Nil(t, os.WriteFile(path, []byte("dummy"), 0644))
+
+		mtime := now.AddDate(0, 0, -days)
+		assert.Nil(t, os.Chtimes(path, mtime, mtime))
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			tc.cfg.root = tempDir
+			assert.Nil(t, run(&buffer, tc.cfg))
+
+			expected := ""
+			for _, name := range tc.expected {
+				expected += filepath.Join(tempDir, name) + "\n"
+			}
+			assert.Equal(t, expected, buffer.String())
+		})
+	}
+}
+
 func createTempDir(t *testing.T,
 	files map[string]int) (dirname string, cleanup func()) {
 	t.Helper()
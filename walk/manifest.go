@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// header of the manifest files
+var manifestHeader = []string{"path", "size", "sha256", "modTime"}
+
+// manifestEntry describes a file recorded in a manifest
+type manifestEntry struct {
+	// path relative to the root, using '/' as separator
+	path    string
+	size    int64
+	hash    string
+	modTime time.Time
+}
+
+// writeManifest records the path, size, SHA-256 and modification time of
+// every matched regular file in the CSV file 'cfg.manifest'. The paths are
+// relative to the root so the tree can be verified after being copied
+// elsewhere.
+func writeManifest(out io.Writer, cfg config) error {
+	f, err := os.Create(cfg.manifest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// the manifest can be written inside the tree
+	self, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	if err := w.Write(manifestHeader); err != nil {
+		return err
+	}
+
+	count := 0
+	err = walkFiles(cfg, nil, func(path string, info fs.FileInfo) error {
+		// only regular files have contents to hash, a pipe would block
+		if os.SameFile(info, self) || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		entry, err := newManifestEntry(cfg.root, path, info)
+		if err != nil {
+			return err
+		}
+
+		count++
+		return w.Write([]string{entry.path,
+			strconv.FormatInt(entry.size, 10), entry.hash,
+			entry.modTime.Format(time.RFC3339Nano)})
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(out, "%d file(s) written to %s\n", count,
+		cfg.manifest)
+	return err
+}
+
+// verifyManifest compares the matched regular files with the manifest
+// 'cfg.checkManifest', reporting the files added, removed and modified since
+// it was written. Files are compared by size and contents, modification
+// times aren't preserved by every copy.
+func verifyManifest(out io.Writer, cfg config) error {
+	entries, err := readManifest(cfg.checkManifest)
+	if err != nil {
+		return err
+	}
+
+	self, err := os.Stat(cfg.checkManifest)
+	if err != nil {
+		return err
+	}
+
+	var added, modified []string
+	seen := map[string]bool{}
+
+	err = walkFiles(cfg, nil, func(path string, info fs.FileInfo) error {
+		if os.SameFile(info, self) || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel := relPath(cfg.root, path)
+		seen[rel] = true
+
+		expected, ok := entries[rel]
+		if !ok {
+			added = append(added, rel)
+			return nil
+		}
+
+		// only hash the files that could be identical
+		if info.Size() != expected.size {
+			modified = append(modified, rel)
+			return nil
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		if hash != expected.hash {
+			modified = append(modified, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	removed := []string{}
+	for rel := range entries {
+		if !seen[rel] {
+			removed = append(removed, rel)
+		}
+	}
+	sort.Strings(removed)
+
+	for _, diff := range []struct {
+		label string
+		paths []string
+	}{
+		{"ADDED", added},
+		{"REMOVED", removed},
+		{"MODIFIED", modified},
+	} {
+		for _, rel := range diff.paths {
+			fmt.Fprintf(out, "%s %s\n", diff.label, rel)
+		}
+	}
+
+	fmt.Fprintf(out, "%d added, %d removed, %d modified\n", len(added),
+		len(removed), len(modified))
+
+	if diffs := len(added) + len(removed) + len(modified); diffs > 0 {
+		return ErrManifestDrift.Errorf(diffs)
+	}
+
+	return nil
+}
+
+func newManifestEntry(root, path string,
+	info fs.FileInfo) (manifestEntry, error) {
+	hash, err := hashFile(path)
+	if err != nil {
+		return manifestEntry{}, err
+	}
+
+	return manifestEntry{
+		path:    relPath(root, path),
+		size:    info.Size(),
+		hash:    hash,
+		modTime: info.ModTime(),
+	}, nil
+}
+
+// readManifest returns the entries of the manifest 'filename' by path
+func readManifest(filename string) (map[string]manifestEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = len(manifestHeader)
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if len(records) == 0 || records[0][0] != manifestHeader[0] {
+		return nil, ErrManifest.Errorf(filename)
+	}
+
+	entries := map[string]manifestEntry{}
+	for _, record := range records[1:] {
+		size, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil {
+			return nil, ErrManifest.Errorf(filename)
+		}
+
+		modTime, err := time.Parse(time.RFC3339Nano, record[3])
+		if err != nil {
+			return nil, ErrManifest.Errorf(filename)
+		}
+
+		entries[record[0]] = manifestEntry{path: record[0], size: size,
+			hash: record[2], modTime: modTime}
+	}
+
+	return entries, nil
+}
+
+// relPath returns the path relative to 'root' using '/' as separator
+func relPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	files := map[string]string{
+		"a.txt":        "alpha",
+		"b.txt":        "bravo",
+		"sub/c.txt":    "charlie",
+		"sub/d.txt":    "delta",
+		"skip/e.other": "echo",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	// the manifest is written inside the tree, it's never part of it
+	manifest := filepath.Join(tempDir, "manifest.csv")
+	cfg := config{root: tempDir, ext: []string{".txt", ".csv"},
+		manifest: manifest}
+
+	var buffer bytes.Buffer
+	assert.Nil(t, writeManifest(&buffer, cfg))
+	assert.Equal(t, "4 file(s) written to "+manifest+"\n", buffer.String())
+
+	entries, err := readManifest(manifest)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(entries))
+	assert.Equal(t, int64(7), entries["sub/c.txt"].size)
+	assert.Equal(t,
+		"8ed3f6ad685b959ead7022518e1af76cd816f8e8ec7ccdda1ed4018e8f2223f8",
+		entries["a.txt"].hash)
+
+	cfg = config{root: tempDir, ext: []string{".txt", ".csv"},
+		checkManifest: manifest}
+
+	t.Run("Unchanged", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		assert.Nil(t, verifyManifest(&buffer, cfg))
+		assert.Equal(t, "0 added, 0 removed, 0 modified\n", buffer.String())
+	})
+
+	t.Run("Changed", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		// same size, different contents
+		assert.Nil(t, os.WriteFile(filepath.Join(tempDir, "a.txt"),
+			[]byte("ALPHA"), 0644))
+		assert.Nil(t, os.WriteFile(filepath.Join(tempDir, "sub", "d.txt"),
+			[]byte("delta delta"), 0644))
+		assert.Nil(t, os.Remove(filepath.Join(tempDir, "b.txt")))
+		assert.Nil(t, os.WriteFile(filepath.Join(tempDir, "sub", "new.txt"),
+			[]byte("new"), 0644))
+
+		err := verifyManifest(&buffer, cfg)
+		assert.Equal(t, ErrManifestDrift.Errorf(4), err)
+		assert.Equal(t, strings.Join([]string{
+			"ADDED sub/new.txt",
+			"REMOVED b.txt",
+			"MODIFIED a.txt",
+			"MODIFIED sub/d.txt",
+			"1 added, 1 removed, 2 modified",
+		}, "\n")+"\n", buffer.String())
+	})
+
+	t.Run("NotManifest", func(t *testing.T) {
+		cfg := config{root: tempDir,
+			checkManifest: filepath.Join(tempDir, "sub", "c.txt")}
+		err := verifyManifest(&bytes.Buffer{}, cfg)
+		assert.NotNil(t, err)
+	})
+}
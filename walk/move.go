@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// what to do when the destination of a moved file already exists
+const (
+	collisionSkip      = "skip"
+	collisionOverwrite = "overwrite"
+	collisionSuffix    = "suffix"
+)
+
+// moveData holds the values available to the move template
+type moveData struct {
+	// file name, e.g. report.pdf
+	Name string
+	// file name without the extension, e.g. report
+	Base string
+	// extension, e.g. .pdf
+	Ext string
+	// directory relative to the root, using '/' as separator
+	Dir string
+	// path relative to the root, using '/' as separator
+	Rel string
+	// modification date, zero padded
+	Year  string
+	Month string
+	Day   string
+}
+
+func newMoveData(root, path string, info fs.FileInfo) moveData {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	rel = filepath.ToSlash(rel)
+
+	name := filepath.Base(path)
+	ext := filepath.Ext(name)
+	mtime := info.ModTime()
+
+	dir := filepath.ToSlash(filepath.Dir(rel))
+	if dir == "." {
+		dir = ""
+	}
+
+	return moveData{
+		Name:  name,
+		Base:  strings.TrimSuffix(name, ext),
+		Ext:   ext,
+		Dir:   dir,
+		Rel:   rel,
+		Year:  mtime.Format("2006"),
+		Month: mtime.Format("01"),
+		Day:   mtime.Format("02"),
+	}
+}
+
+// movePath returns where the file at 'path' is moved to. Without a template
+// the file keeps its location relative to the root.
+func movePath(path string, info fs.FileInfo, cfg config) (string, error) {
+	data := newMoveData(cfg.root, path, info)
+
+	rel := data.Rel
+	if cfg.moveTemplate != nil {
+		var sb strings.Builder
+		if err := cfg.moveTemplate.Execute(&sb, data); err != nil {
+			return "", err
+		}
+		rel = sb.String()
+	}
+
+	// the template must not escape the destination directory
+	rel = filepath.Clean(filepath.FromSlash(rel))
+	if rel == "." || rel == ".." || filepath.IsAbs(rel) ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrMoveTarget.Errorf(rel)
+	}
+
+	return filepath.Join(cfg.move, rel), nil
+}
+
+// suffixPath returns the first of 'path', 'name-1.ext', 'name-2.ext'... that
+// doesn't exist.
+func suffixPath(path string) (string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	for i := 0; ; i++ {
+		candidate := path
+		if i > 0 {
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+
+		_, err := os.Lstat(candidate)
+		if os.IsNotExist(err) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// relocateFile moves the file at 'path' into the move directory, applying
+// the collision strategy when the destination exists. It reports whether
+// the file was moved.
+func relocateFile(path string, info fs.FileInfo, cfg config,
+	moveLogger *log.Logger) (bool, error) {
+	dst, err := movePath(path, info, cfg)
+	if err != nil {
+		return false, err
+	}
+
+	// the file is already where it belongs
+	if filepath.Clean(path) == dst {
+		return false, nil
+	}
+
+	if _, err := os.Lstat(dst); err == nil {
+		switch cfg.collision {
+		case collisionOverwrite:
+		case collisionSuffix:
+			if dst, err = suffixPath(dst); err != nil {
+				return false, err
+			}
+		default:
+			return false, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	if !cfg.dryRun {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return false, err
+		}
+
+		err := moveFile(path, dst)
+		// copying to another file system doesn't replace the destination
+		if errors.Is(err, fs.ErrExist) && cfg.collision == collisionOverwrite {
+			if err = os.Remove(dst); err == nil {
+				err = moveFile(path, dst)
+			}
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	moveLogger.Printf("%s -> %s", path, dst)
+	return true, nil
+}
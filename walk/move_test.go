@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMovePath(t *testing.T) {
+	root := filepath.FromSlash("/data")
+	dest := filepath.FromSlash("/sorted")
+	path := filepath.Join(root, "reports", "q1.pdf")
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".pdf": 1})
+	defer cleanup()
+
+	info, err := os.Stat(filepath.Join(tempDir, "file0.pdf"))
+	assert.Nil(t, err)
+	mtime := info.ModTime()
+
+	testCases := []struct {
+		testName string
+		template string
+		expected string
+		fails    bool
+	}{
+		{testName: "Default", expected: "reports/q1.pdf"},
+		{testName: "Date", template: "{{.Year}}/{{.Month}}/{{.Day}}/{{.Name}}",
+			expected: mtime.Format("2006/01/02") + "/q1.pdf"},
+		{testName: "Rename", template: "{{.Dir}}/{{.Base}}-{{.Year}}{{.Ext}}",
+			expected: "reports/q1-" + mtime.Format("2006") + ".pdf"},
+		{testName: "Escape", template: "../{{.Name}}", fails: true},
+		{testName: "Empty", template: "{{if false}}x{{end}}", fails: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			cfg := config{root: root, move: dest}
+			if tc.template != "" {
+				var tf templateFlag
+				assert.Nil(t, tf.Set(tc.template))
+				cfg.moveTemplate = tf.t
+			}
+
+			result, err := movePath(path, info, cfg)
+			if tc.fails {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, filepath.Join(dest, filepath.FromSlash(tc.expected)),
+				result)
+		})
+	}
+}
+
+func TestRunMove(t *testing.T) {
+	mtime := time.Date(2021, 3, 7, 12, 0, 0, 0, time.Local)
+
+	testCases := []struct {
+		testName  string
+		collision string
+		moved     int
+		expected  map[string]string
+	}{
+		{testName: "Skip", collision: collisionSkip, moved: 1,
+			expected: map[string]string{"a.txt": "existing", "b.txt": "b"}},
+		{testName: "Overwrite", collision: collisionOverwrite, moved: 2,
+			expected: map[string]string{"a.txt": "a", "b.txt": "b"}},
+		{testName: "Suffix", collision: collisionSuffix, moved: 2,
+			expected: map[string]string{"a.txt": "existing", "a-1.txt": "a",
+				"b.txt": "b"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer, logBuffer bytes.Buffer
+
+			tempDir, cleanup := createTempDir(t, nil)
+			defer cleanup()
+
+			// the move directory is inside the root, its files aren't matched
+			moveDir := filepath.Join(tempDir, "sorted")
+			existing := filepath.Join(moveDir, "2021", "03", "a.txt")
+			assert.Nil(t, os.MkdirAll(filepath.Dir(existing), 0755))
+			assert.Nil(t, os.WriteFile(existing, []byte("existing"), 0644))
+
+			for name, content := range map[string]string{"a.txt": "a",
+				"sub/b.txt": "b"} {
+				path := filepath.Join(tempDir, filepath.FromSlash(name))
+				assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+				assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+				assert.Nil(t, os.Chtimes(path, mtime, mtime))
+			}
+
+			var tf templateFlag
+			assert.Nil(t, tf.Set("{{.Year}}/{{.Month}}/{{.Name}}"))
+
+			cfg := config{root: tempDir, move: moveDir, moveTemplate: tf.t,
+				collision: tc.collision, format: formatJSON, wLog: &logBuffer}
+			assert.Nil(t, run(&buffer, cfg))
+
+			assert.Equal(t, tc.moved,
+				strings.Count(logBuffer.String(), moveLogPrefix))
+			assert.Equal(t, tc.moved,
+				strings.Count(buffer.String(), `"action":"move"`))
+			assert.Contains(t, buffer.String(), `"filesMatched":2,`)
+
+			entries, err := os.ReadDir(filepath.Join(moveDir, "2021", "03"))
+			assert.Nil(t, err)
+			assert.Equal(t, len(tc.expected), len(entries))
+
+			for name, content := range tc.expected {
+				data, err := os.ReadFile(filepath.Join(moveDir, "2021", "03",
+					name))
+				assert.Nil(t, err)
+				assert.Equal(t, content, string(data), name)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// events that can make a file appear or change
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_DELETE |
+	syscall.IN_ATTRIB
+
+// inotifyNotifier wakes the watch up on inotify events. Files written
+// without being closed, such as logs, don't wake it up and are found when
+// scanning.
+type inotifyNotifier struct {
+	fd   int
+	file *os.File
+	// directories watched, only used by the watch goroutine
+	watched map[string]bool
+	wake    chan struct{}
+}
+
+func newNotifier() (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	// a non-blocking descriptor lets close interrupt the pending read
+	n := &inotifyNotifier{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watched: map[string]bool{},
+		wake:    make(chan struct{}, 1),
+	}
+	go n.read()
+
+	return n, nil
+}
+
+// add watches 'dir'. Failures, such as reaching the limit of watches, are
+// ignored since the changes are still found when scanning.
+func (n *inotifyNotifier) add(dir string) {
+	if n.watched[dir] {
+		return
+	}
+
+	n.watched[dir] = true
+	syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+}
+
+// read wakes the watch up whenever events are read, until it's closed. The
+// events themselves don't matter, the watch scans the whole tree.
+func (n *inotifyNotifier) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		if _, err := n.file.Read(buf); err != nil {
+			return
+		}
+
+		select {
+		case n.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *inotifyNotifier) events() <-chan struct{} {
+	return n.wake
+}
+
+func (n *inotifyNotifier) close() error {
+	return n.file.Close()
+}
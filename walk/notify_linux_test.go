@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInotifyNotifier(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	n, err := newNotifier()
+	assert.Nil(t, err)
+	n.add(tempDir)
+
+	path := filepath.Join(tempDir, "new.log")
+	assert.Nil(t, os.WriteFile(path, []byte("alpha"), 0644))
+
+	select {
+	case <-n.events():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event for the new file")
+	}
+
+	// closing ends the pending read
+	assert.Nil(t, n.close())
+}
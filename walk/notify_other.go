@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+// newNotifier returns a notifier that never wakes the watch up, the changes
+// are found by scanning.
+func newNotifier() (notifier, error) {
+	return pollNotifier{}, nil
+}
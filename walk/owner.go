@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/fs"
+	"os/user"
+	"sync"
+)
+
+// kinds of owners
+const (
+	ownerUser  = "user"
+	ownerGroup = "group"
+)
+
+// knownOwners caches whether the user and group IDs exist, keyed by kind and
+// ID, since looking them up for every file is slow
+var knownOwners = struct {
+	sync.Mutex
+	ids map[string]bool
+}{ids: map[string]bool{}}
+
+// lookupUser returns the ID of the user 'name'
+func lookupUser(name string) (string, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", err
+	}
+
+	return u.Uid, nil
+}
+
+// lookupGroup returns the ID of the group 'name'
+func lookupGroup(name string) (string, error) {
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Gid, nil
+}
+
+// ownerExists reports whether there's a user or group with the ID 'id'
+func ownerExists(kind, id string) bool {
+	knownOwners.Lock()
+	defer knownOwners.Unlock()
+
+	key := kind + ":" + id
+	if exists, ok := knownOwners.ids[key]; ok {
+		return exists
+	}
+
+	var err error
+	if kind == ownerUser {
+		_, err = user.LookupId(id)
+	} else {
+		_, err = user.LookupGroupId(id)
+	}
+
+	knownOwners.ids[key] = err == nil
+	return err == nil
+}
+
+// matchOwner reports whether the file is owned by the configured user and
+// group. Files whose owner can't be determined never match an owner filter.
+func matchOwner(info fs.FileInfo, cfg config) bool {
+	if cfg.uid == "" && cfg.gid == "" && !cfg.noUser && !cfg.noGroup {
+		return true
+	}
+
+	uid, gid, ok := fileOwner(info)
+	switch {
+	case !ok:
+	case cfg.uid != "" && uid != cfg.uid:
+	case cfg.gid != "" && gid != cfg.gid:
+	case cfg.noUser && ownerExists(ownerUser, uid):
+	case cfg.noGroup && ownerExists(ownerGroup, gid):
+	default:
+		return true
+	}
+
+	return false
+}
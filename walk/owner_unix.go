@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"io/fs"
+	"strconv"
+	"syscall"
+)
+
+// fileOwner returns the user and group IDs owning the file
+func fileOwner(info fs.FileInfo) (string, string, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", false
+	}
+
+	return strconv.FormatUint(uint64(stat.Uid), 10),
+		strconv.FormatUint(uint64(stat.Gid), 10), true
+}
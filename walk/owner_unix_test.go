@@ -0,0 +1,110 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunFileFilters(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	files := map[string]os.FileMode{
+		"private.txt": 0600,
+		"shared.txt":  0666,
+		"script.sh":   0755,
+	}
+	for name, mode := range files {
+		path := filepath.Join(tempDir, name)
+		assert.Nil(t, os.WriteFile(path, []byte("dummy"), mode))
+		// the umask may have removed some bits
+		assert.Nil(t, os.Chmod(path, mode))
+	}
+	assert.Nil(t, os.Mkdir(filepath.Join(tempDir, "sub"), 0755))
+	assert.Nil(t, os.Symlink("script.sh", filepath.Join(tempDir, "link")))
+	assert.Nil(t, syscall.Mkfifo(filepath.Join(tempDir, "pipe"), 0644))
+
+	uid := strconv.Itoa(os.Getuid())
+	gid := strconv.Itoa(os.Getgid())
+
+	var worldWritable, setuid permFlag
+	assert.Nil(t, worldWritable.Set("/002"))
+	assert.Nil(t, setuid.Set("-4000"))
+
+	testCases := []struct {
+		testName string
+		cfg      config
+		expected []string
+	}{
+		{testName: "TypeFile", cfg: config{types: "f"},
+			expected: []string{"private.txt", "script.sh", "shared.txt"}},
+		{testName: "TypeDir", cfg: config{types: "d", minDepth: 1},
+			expected: []string{"sub"}},
+		{testName: "TypeLinkPipe", cfg: config{types: "l,p"},
+			expected: []string{"link -> script.sh", "pipe"}},
+		{testName: "Executable", cfg: config{types: "f", executable: true},
+			expected: []string{"script.sh"}},
+		{testName: "WorldWritable", cfg: config{types: "f",
+			perm: worldWritable}, expected: []string{"shared.txt"}},
+		{testName: "Setuid", cfg: config{perm: setuid}, expected: []string{}},
+		{testName: "Owner", cfg: config{types: "f", uid: uid, gid: gid},
+			expected: []string{"private.txt", "script.sh", "shared.txt"}},
+		{testName: "OtherOwner", cfg: config{uid: uid + "1"},
+			expected: []string{}},
+		{testName: "NoUser", cfg: config{noUser: true, noGroup: true},
+			expected: []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			tc.cfg.root = tempDir
+			tc.cfg.list = true
+			assert.Nil(t, run(&buffer, tc.cfg))
+
+			expected := ""
+			for _, name := range tc.expected {
+				expected += filepath.Join(tempDir, name) + "\n"
+			}
+			assert.Equal(t, expected, buffer.String())
+		})
+	}
+}
+
+func TestRunChown(t *testing.T) {
+	var buffer bytes.Buffer
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 2})
+	defer cleanup()
+
+	uid := strconv.Itoa(os.Getuid())
+	gid := strconv.Itoa(os.Getgid())
+
+	// files already owned by the new owner aren't changed
+	cfg := config{root: tempDir, chown: ownerFlag{uid: uid, gid: gid},
+		wLog: &buffer}
+	assert.Nil(t, run(&buffer, cfg))
+	assert.Empty(t, buffer.String())
+
+	// changing the owner needs privileges, so only try it
+	other := strconv.Itoa(os.Getuid() + 1)
+	cfg = config{root: tempDir, chown: ownerFlag{uid: other}, dryRun: true,
+		wLog: &buffer}
+	assert.Nil(t, run(&buffer, cfg))
+
+	result := buffer.String()
+	assert.Equal(t, 2, strings.Count(result, dryRunPrefix+chownLogPrefix))
+	assert.Contains(t, result, filepath.Join(tempDir, "file0.log")+" "+uid+
+		":"+gid+" -> "+other+":"+gid+"\n")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strconv"
+)
+
+// changeMode applies the permission change to the file at 'path', logging
+// the old and new permissions. It reports whether the permissions changed.
+// Symbolic links have no permissions of their own, so they're left alone.
+func changeMode(path string, info fs.FileInfo, mf modeFlag,
+	chmodLogger *log.Logger, dryRun bool) (bool, error) {
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return false, nil
+	}
+
+	oldMode := info.Mode() & permBits
+	newMode := mf.apply(oldMode)
+	if newMode == oldMode {
+		return false, nil
+	}
+
+	if !dryRun {
+		if err := os.Chmod(path, newMode); err != nil {
+			return false, err
+		}
+	}
+
+	chmodLogger.Printf("%s %s -> %s", path, formatOctalMode(oldMode),
+		formatOctalMode(newMode))
+	return true, nil
+}
+
+// changeOwner changes the owner and group of the file at 'path', logging
+// the old and new IDs. It reports whether they changed. Links that aren't
+// followed are changed themselves, not their targets.
+func changeOwner(path string, info fs.FileInfo, of ownerFlag,
+	chownLogger *log.Logger, dryRun bool) (bool, error) {
+	oldUID, oldGID, ok := fileOwner(info)
+	if !ok {
+		return false, fmt.Errorf("%s: owner not available", path)
+	}
+
+	newUID, newGID := oldUID, oldGID
+	if of.uid != "" {
+		newUID = of.uid
+	}
+	if of.gid != "" {
+		newGID = of.gid
+	}
+	if newUID == oldUID && newGID == oldGID {
+		return false, nil
+	}
+
+	if !dryRun {
+		uid, err := strconv.Atoi(newUID)
+		if err != nil {
+			return false, err
+		}
+		gid, err := strconv.Atoi(newGID)
+		if err != nil {
+			return false, err
+		}
+
+		chown := os.Lchown
+		if _, followed := info.(followedLink); followed {
+			chown = os.Chown
+		}
+		if err := chown(path, uid, gid); err != nil {
+			return false, err
+		}
+	}
+
+	chownLogger.Printf("%s %s:%s -> %s:%s", path, oldUID, oldGID, newUID,
+		newGID)
+	return true, nil
+}
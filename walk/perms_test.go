@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunChmod(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	files := map[string]os.FileMode{"shared.txt": 0666, "private.txt": 0600}
+	for name, mode := range files {
+		path := filepath.Join(tempDir, name)
+		assert.Nil(t, os.WriteFile(path, []byte("dummy"), mode))
+		assert.Nil(t, os.Chmod(path, mode))
+	}
+
+	var mf modeFlag
+	assert.Nil(t, mf.Set("go-w"))
+
+	testCases := []struct {
+		testName string
+		dryRun   bool
+		expected os.FileMode
+	}{
+		{testName: "DryRun", dryRun: true, expected: 0666},
+		{testName: "Change", expected: 0644},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer, logBuffer bytes.Buffer
+
+			cfg := config{root: tempDir, chmod: mf, dryRun: tc.dryRun,
+				format: formatNDJSON, wLog: &logBuffer}
+			assert.Nil(t, run(&buffer, cfg))
+
+			// only the files whose permissions change are logged
+			logs := logBuffer.String()
+			assert.Equal(t, 1, strings.Count(logs, chmodLogPrefix))
+			assert.Contains(t, logs, filepath.Join(tempDir, "shared.txt")+
+				" 0666 -> 0644\n")
+			assert.Equal(t, 1, strings.Count(buffer.String(),
+				`"action":"chmod"`))
+
+			info, err := os.Stat(filepath.Join(tempDir, "shared.txt"))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, info.Mode().Perm())
+		})
+	}
+}
@@ -0,0 +1,130 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createPipeTree creates a tree holding a regular file and a named pipe,
+// which blocks whoever opens it for reading
+func createPipeTree(t *testing.T) (string, func()) {
+	t.Helper()
+
+	mtime := time.Date(2022, 5, 1, 10, 0, 0, 0, time.Local)
+	tempDir, cleanup := createTree(t, map[string]string{"a.txt": "alpha"},
+		mtime)
+
+	pipe := filepath.Join(tempDir, "pipe")
+	assert.Nil(t, syscall.Mkfifo(pipe, 0644))
+	assert.Nil(t, os.Chtimes(pipe, mtime, mtime))
+
+	return tempDir, cleanup
+}
+
+// runWithin returns the error of 'fn', failing the test if it doesn't
+// return within a few seconds
+func runWithin(t *testing.T, fn func() error) error {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked on the named pipe")
+		return nil
+	}
+}
+
+func TestRunArchivePipe(t *testing.T) {
+	tempDir, cleanup := createPipeTree(t)
+	defer cleanup()
+
+	archiveDir, cleanupArchive := createTempDir(t, nil)
+	defer cleanupArchive()
+
+	var buffer, logBuffer bytes.Buffer
+	cfg := config{root: tempDir, archive: archiveDir, del: true,
+		wLog: &logBuffer}
+
+	// the pipe is rejected rather than deleted without a copy
+	err := runWithin(t, func() error { return run(&buffer, cfg) })
+	assert.Equal(t, ErrNotRegular.Errorf(filepath.Join(tempDir, "pipe")), err)
+
+	_, err = os.Stat(filepath.Join(archiveDir, "a.txt.gz"))
+	assert.Nil(t, err)
+	_, err = os.Lstat(filepath.Join(tempDir, "pipe"))
+	assert.Nil(t, err)
+}
+
+func TestManifestPipe(t *testing.T) {
+	tempDir, cleanup := createPipeTree(t)
+	defer cleanup()
+
+	var buffer bytes.Buffer
+	cfg := config{root: tempDir, manifest: filepath.Join(tempDir, "files.csv")}
+	assert.Nil(t, runWithin(t, func() error {
+		return writeManifest(&buffer, cfg)
+	}))
+	assert.Contains(t, buffer.String(), "1 file(s) written")
+
+	// the pipe is left out of the verification too
+	cfg = config{root: tempDir, checkManifest: cfg.manifest}
+	buffer.Reset()
+	assert.Nil(t, runWithin(t, func() error {
+		return verifyManifest(&buffer, cfg)
+	}))
+	assert.Contains(t, buffer.String(), "0 added, 0 removed, 0 modified")
+}
+
+func TestCompareTreesPipe(t *testing.T) {
+	left, cleanupLeft := createPipeTree(t)
+	defer cleanupLeft()
+	right, cleanupRight := createPipeTree(t)
+	defer cleanupRight()
+
+	// a pipe and a file of the same name differ by type
+	assert.Nil(t, os.Remove(filepath.Join(right, "a.txt")))
+	assert.Nil(t, syscall.Mkfifo(filepath.Join(right, "a.txt"), 0644))
+
+	var buffer bytes.Buffer
+	cfg := config{root: left, compare: right, compareContent: true}
+	err := runWithin(t, func() error { return compareTrees(&buffer, cfg) })
+	assert.Equal(t, ErrTreesDiffer.Errorf(1), err)
+	assert.Equal(t, "DIFFER (type): a.txt\n"+
+		"0 only in "+left+", 0 only in "+right+", 1 different\n",
+		buffer.String())
+}
+
+func TestRunSyncPipe(t *testing.T) {
+	tempDir, cleanup := createPipeTree(t)
+	defer cleanup()
+
+	dest, cleanupDest := createTempDir(t, nil)
+	defer cleanupDest()
+
+	var logBuffer bytes.Buffer
+	cfg := config{root: tempDir, sync: dest, syncDelete: true,
+		wLog: &logBuffer}
+	assert.Nil(t, runWithin(t, func() error {
+		return run(&bytes.Buffer{}, cfg)
+	}))
+
+	_, err := os.Stat(filepath.Join(dest, "a.txt"))
+	assert.Nil(t, err)
+	_, err = os.Lstat(filepath.Join(dest, "pipe"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profile is a named set of options read from the config file: the root,
+// traversal options, filters, actions and log file. Sizes, ages,
+// permissions, owners and templates accept the same values as the
+// corresponding flags. The modes, such as dupes or watch, are only flags.
+type profile struct {
+	Root         string   `yaml:"root"`
+	MaxDepth     int      `yaml:"maxDepth"`
+	MinDepth     int      `yaml:"minDepth"`
+	Xdev         bool     `yaml:"xdev"`
+	Symlinks     string   `yaml:"symlinks"`
+	Gitignore    bool     `yaml:"gitignore"`
+	IgnoreFiles  []string `yaml:"ignoreFiles"`
+	Ext          []string `yaml:"ext"`
+	Include      []string `yaml:"include"`
+	Exclude      []string `yaml:"exclude"`
+	Match        string   `yaml:"match"`
+	MinSize      string   `yaml:"minSize"`
+	MaxSize      string   `yaml:"maxSize"`
+	OlderThan    string   `yaml:"olderThan"`
+	NewerThan    string   `yaml:"newerThan"`
+	Type         string   `yaml:"type"`
+	Executable   bool     `yaml:"executable"`
+	Perm         string   `yaml:"perm"`
+	User         string   `yaml:"user"`
+	Group        string   `yaml:"group"`
+	NoUser       bool     `yaml:"noUser"`
+	NoGroup      bool     `yaml:"noGroup"`
+	List         bool     `yaml:"list"`
+	Del          bool     `yaml:"del"`
+	Archive      string   `yaml:"archive"`
+	Trash        bool     `yaml:"trash"`
+	SafeDel      bool     `yaml:"safeDel"`
+	TrashDir     string   `yaml:"trashDir"`
+	Move         string   `yaml:"move"`
+	MoveTemplate string   `yaml:"moveTemplate"`
+	Collision    string   `yaml:"collision"`
+	Sync         string   `yaml:"sync"`
+	SyncDelete   bool     `yaml:"syncDelete"`
+	Chmod        string   `yaml:"chmod"`
+	Chown        string   `yaml:"chown"`
+	Exec         string   `yaml:"exec"`
+	ExecBatch    string   `yaml:"execBatch"`
+	ExecFail     string   `yaml:"execFail"`
+	KeepLast     int      `yaml:"keepLast"`
+	Workers      int      `yaml:"workers"`
+	Log          string   `yaml:"log"`
+}
+
+// profilesFile is the layout of the config file
+type profilesFile struct {
+	Profiles map[string]profile `yaml:"profiles"`
+}
+
+// loadProfiles reads the profiles defined in the YAML file 'filename'.
+// Unknown options are rejected so that typos don't go unnoticed.
+func loadProfiles(filename string) (map[string]profile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var pf profilesFile
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&pf); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if len(pf.Profiles) == 0 {
+		return nil, ErrNoProfiles.Errorf(filename)
+	}
+
+	return pf.Profiles, nil
+}
+
+// apply returns a copy of 'base' with the options set in the profile
+func (p profile) apply(base config) (config, error) {
+	cfg := base
+
+	if p.Root != "" {
+		cfg.root = p.Root
+	}
+	if p.Archive != "" {
+		cfg.archive = p.Archive
+	}
+	if p.TrashDir != "" {
+		cfg.trashDir = p.TrashDir
+	}
+	if p.Workers != 0 {
+		cfg.workers = p.Workers
+	}
+	if p.KeepLast != 0 {
+		cfg.keepLast = p.KeepLast
+	}
+	if p.MaxDepth != 0 {
+		cfg.maxDepth = p.MaxDepth
+	}
+	if p.MinDepth != 0 {
+		cfg.minDepth = p.MinDepth
+	}
+	// these are validated along with the flags when the profile runs
+	if p.Symlinks != "" {
+		cfg.symlinks = p.Symlinks
+	}
+	if p.Type != "" {
+		cfg.types = p.Type
+	}
+	if p.Move != "" {
+		cfg.move = p.Move
+	}
+	if p.Collision != "" {
+		cfg.collision = p.Collision
+	}
+	if p.Sync != "" {
+		cfg.sync = p.Sync
+	}
+	if p.Exec != "" {
+		cfg.exec = p.Exec
+	}
+	if p.ExecBatch != "" {
+		cfg.execBatch = p.ExecBatch
+	}
+	if p.ExecFail != "" {
+		cfg.execFail = p.ExecFail
+	}
+	cfg.list = cfg.list || p.List
+	cfg.del = cfg.del || p.Del
+	cfg.trash = cfg.trash || p.Trash
+	cfg.safeDel = cfg.safeDel || p.SafeDel
+	cfg.syncDelete = cfg.syncDelete || p.SyncDelete
+	cfg.xdev = cfg.xdev || p.Xdev
+	cfg.gitignore = cfg.gitignore || p.Gitignore
+	cfg.executable = cfg.executable || p.Executable
+	cfg.noUser = cfg.noUser || p.NoUser
+	cfg.noGroup = cfg.noGroup || p.NoGroup
+
+	if len(p.IgnoreFiles) > 0 {
+		cfg.ignoreFiles = p.IgnoreFiles
+	}
+	if len(p.Ext) > 0 {
+		cfg.ext = p.Ext
+	}
+
+	// reuse the flag values to validate the options the same way
+	var include, exclude patternList
+	for _, pattern := range p.Include {
+		if err := include.Set(pattern); err != nil {
+			return cfg, err
+		}
+	}
+	for _, pattern := range p.Exclude {
+		if err := exclude.Set(pattern); err != nil {
+			return cfg, err
+		}
+	}
+	if len(include) > 0 {
+		cfg.include = include
+	}
+	if len(exclude) > 0 {
+		cfg.exclude = exclude
+	}
+
+	if p.Match != "" {
+		var match regexpFlag
+		if err := match.Set(p.Match); err != nil {
+			return cfg, err
+		}
+		cfg.match = match.re
+	}
+
+	var minSize, maxSize sizeFlag
+	if p.MinSize != "" {
+		if err := minSize.Set(p.MinSize); err != nil {
+			return cfg, err
+		}
+		cfg.minSize = uint64(minSize)
+	}
+	if p.MaxSize != "" {
+		if err := maxSize.Set(p.MaxSize); err != nil {
+			return cfg, err
+		}
+		cfg.maxSize = uint64(maxSize)
+	}
+
+	var olderThan, newerThan timeFlag
+	if p.OlderThan != "" {
+		if err := olderThan.Set(p.OlderThan); err != nil {
+			return cfg, err
+		}
+		cfg.olderThan = olderThan.t
+	}
+	if p.NewerThan != "" {
+		if err := newerThan.Set(p.NewerThan); err != nil {
+			return cfg, err
+		}
+		cfg.newerThan = newerThan.t
+	}
+
+	if p.Perm != "" {
+		var perm permFlag
+		if err := perm.Set(p.Perm); err != nil {
+			return cfg, err
+		}
+		cfg.perm = perm
+	}
+	if p.User != "" {
+		uid := idFlag{lookup: lookupUser}
+		if err := uid.Set(p.User); err != nil {
+			return cfg, err
+		}
+		cfg.uid = uid.id
+	}
+	if p.Group != "" {
+		gid := idFlag{lookup: lookupGroup}
+		if err := gid.Set(p.Group); err != nil {
+			return cfg, err
+		}
+		cfg.gid = gid.id
+	}
+
+	if p.MoveTemplate != "" {
+		var moveTemplate templateFlag
+		if err := moveTemplate.Set(p.MoveTemplate); err != nil {
+			return cfg, err
+		}
+		cfg.moveTemplate = moveTemplate.t
+	}
+	if p.Chmod != "" {
+		var chmod modeFlag
+		if err := chmod.Set(p.Chmod); err != nil {
+			return cfg, err
+		}
+		cfg.chmod = chmod
+	}
+	if p.Chown != "" {
+		var chown ownerFlag
+		if err := chown.Set(p.Chown); err != nil {
+			return cfg, err
+		}
+		cfg.chown = chown
+	}
+
+	return cfg, nil
+}
+
+// runProfiles runs the profile 'name', or every profile in alphabetical order
+// when 'name' is empty. A failing profile doesn't prevent the others from
+// running; the errors are returned together. In the structured formats the
+// records name their profile and a single summary covers all of them.
+func runProfiles(out io.Writer, base config, logFile string,
+	profiles map[string]profile, name string) error {
+	names := []string{name}
+	if name == "" {
+		names = make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+	}
+
+	// the structured formats hold the files of every profile in a single
+	// document with one summary, text lists each profile in turn
+	text := base.format == "" || base.format == formatText
+	var shared reporter
+	total := newSummary(base.dryRun)
+	if !text {
+		shared = newReporter(out, base)
+		if cr, ok := shared.(*csvReporter); ok {
+			cr.profiles = true
+		}
+	}
+
+	var errs actionErrors
+	for _, n := range names {
+		p, ok := profiles[n]
+		if !ok {
+			return ErrProfileNotFound.Errorf(n)
+		}
+
+		if len(names) > 1 && text {
+			fmt.Fprintf(out, "PROFILE: %s\n", n)
+		}
+
+		cfg, err := p.apply(base)
+		if err == nil {
+			log := logFile
+			if p.Log != "" {
+				log = p.Log
+			}
+			if shared != nil {
+				cfg.reporter = &profileReporter{rep: shared, name: n,
+					total: total}
+			}
+			err = execute(out, cfg, log)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n, err))
+		}
+	}
+
+	if shared != nil {
+		if err := shared.summarize(*total); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// profileReporter reports the files matched by a profile to the reporter
+// shared by the profiles, naming the profile in each record. The summaries
+// are added up to be written once all the profiles ran.
+type profileReporter struct {
+	rep   reporter
+	name  string
+	total *summary
+}
+
+func (pr *profileReporter) report(r fileRecord) error {
+	r.Profile = pr.name
+	return pr.rep.report(r)
+}
+
+func (pr *profileReporter) summarize(s summary) error {
+	pr.total.merge(s)
+	return nil
+}
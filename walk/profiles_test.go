@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, dir, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "walk.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+
+	return path
+}
+
+func TestLoadProfiles(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	testCases := []struct {
+		testName string
+		content  string
+		profiles []string
+		fails    bool
+	}{
+		{testName: "Valid", content: `
+profiles:
+  logs:
+    root: /var/log
+    ext: [.log, .tmp]
+    olderThan: 30d
+    del: true
+  reports:
+    root: /srv/reports
+    list: true
+`, profiles: []string{"logs", "reports"}},
+		{testName: "UnknownOption", content: `
+profiles:
+  logs:
+    root: /var/log
+    delete: true
+`, fails: true},
+		{testName: "NoProfiles", content: "", fails: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			path := writeConfigFile(t, tempDir, tc.content)
+
+			profiles, err := loadProfiles(path)
+			if tc.fails {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			names := []string{}
+			for name := range profiles {
+				names = append(names, name)
+			}
+			assert.ElementsMatch(t, tc.profiles, names)
+		})
+	}
+}
+
+func TestProfileApply(t *testing.T) {
+	base := config{root: ".", workers: 1, dryRun: true}
+
+	p := profile{
+		Root:         "/var/log",
+		Ext:          []string{".log"},
+		Exclude:      []string{"keep/"},
+		Match:        `app-\d+`,
+		MinSize:      "10K",
+		MaxSize:      "1M",
+		OlderThan:    "30d",
+		Del:          true,
+		Archive:      "/backup",
+		Workers:      4,
+		KeepLast:     7,
+		MaxDepth:     3,
+		Symlinks:     symlinksSkip,
+		Type:         "f",
+		Perm:         "-022",
+		User:         "0",
+		Chmod:        "640",
+		Chown:        ":0",
+		Move:         "/sorted",
+		MoveTemplate: "{{.Year}}/{{.Name}}",
+		Exec:         "gzip {}",
+		ExecFail:     execFailStop,
+	}
+
+	cfg, err := p.apply(base)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "/var/log", cfg.root)
+	assert.Equal(t, []string{".log"}, cfg.ext)
+	assert.Equal(t, []string{"keep/"}, cfg.exclude)
+	assert.True(t, cfg.match.MatchString("app-12.log"))
+	assert.Equal(t, uint64(10*1024), cfg.minSize)
+	assert.Equal(t, uint64(1024*1024), cfg.maxSize)
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, -30), cfg.olderThan,
+		time.Minute)
+	assert.True(t, cfg.newerThan.IsZero())
+	assert.True(t, cfg.del)
+	assert.Equal(t, "/backup", cfg.archive)
+	assert.Equal(t, 4, cfg.workers)
+	assert.Equal(t, 7, cfg.keepLast)
+	assert.Equal(t, 3, cfg.maxDepth)
+	assert.Equal(t, symlinksSkip, cfg.symlinks)
+	assert.Equal(t, "f", cfg.types)
+	assert.Equal(t, permFlag{mode: 0022, op: '-'}, cfg.perm)
+	assert.Equal(t, "0", cfg.uid)
+	assert.Equal(t, "640", cfg.chmod.String())
+	assert.Equal(t, ownerFlag{gid: "0"}, cfg.chown)
+	assert.Equal(t, "/sorted", cfg.move)
+	assert.NotNil(t, cfg.moveTemplate)
+	assert.Equal(t, "gzip {}", cfg.exec)
+	assert.Equal(t, execFailStop, cfg.execFail)
+	// options not set in the profile are kept
+	assert.True(t, cfg.dryRun)
+
+	_, err = profile{MinSize: "ten"}.apply(base)
+	assert.NotNil(t, err)
+	_, err = profile{Include: []string{"[a-"}}.apply(base)
+	assert.NotNil(t, err)
+	_, err = profile{Perm: "999"}.apply(base)
+	assert.NotNil(t, err)
+	_, err = profile{MoveTemplate: "{{.Size}}"}.apply(base)
+	assert.NotNil(t, err)
+}
+
+func TestRunProfiles(t *testing.T) {
+	logsDir, cleanupLogs := createTempDir(t, map[string]int{".log": 2,
+		".gz": 1})
+	defer cleanupLogs()
+
+	tmpDir, cleanupTmp := createTempDir(t, map[string]int{".tmp": 3})
+	defer cleanupTmp()
+
+	configDir, cleanupConfig := createTempDir(t, nil)
+	defer cleanupConfig()
+
+	logFile := filepath.Join(configDir, "tmp.log")
+	path := writeConfigFile(t, configDir, fmt.Sprintf(`
+profiles:
+  logs:
+    root: %s
+    ext: [.log]
+    list: true
+  tmp:
+    root: %s
+    ext: [tmp]
+    del: true
+    log: %s
+`, logsDir, tmpDir, logFile))
+
+	profiles, err := loadProfiles(path)
+	assert.Nil(t, err)
+
+	base := config{root: ".", workers: 1}
+
+	t.Run("SingleProfile", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		assert.Nil(t, runProfiles(&buffer, base, "", profiles, "logs"))
+		assert.Equal(t, 2, strings.Count(buffer.String(), logsDir))
+		assert.NotContains(t, buffer.String(), "PROFILE: ")
+	})
+
+	t.Run("AllProfiles", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		assert.Nil(t, runProfiles(&buffer, base, "", profiles, ""))
+		result := buffer.String()
+		assert.True(t, strings.HasPrefix(result, "PROFILE: logs\n"))
+		assert.Contains(t, result, "PROFILE: tmp\n")
+
+		remaining, err := os.ReadDir(tmpDir)
+		assert.Nil(t, err)
+		assert.Empty(t, remaining)
+
+		// the deletions are logged to the profile's log file
+		data, err := os.ReadFile(logFile)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, strings.Count(string(data), delLogPrefix))
+	})
+
+	t.Run("ProfileNotFound", func(t *testing.T) {
+		err := runProfiles(&bytes.Buffer{}, base, "", profiles, "missing")
+		assert.Equal(t, ErrProfileNotFound.Errorf("missing"), err)
+	})
+}
+
+func TestRunProfilesFormats(t *testing.T) {
+	logsDir, cleanupLogs := createTempDir(t, map[string]int{".log": 2})
+	defer cleanupLogs()
+
+	tmpDir, cleanupTmp := createTempDir(t, map[string]int{".tmp": 1})
+	defer cleanupTmp()
+
+	configDir, cleanupConfig := createTempDir(t, nil)
+	defer cleanupConfig()
+
+	path := writeConfigFile(t, configDir, fmt.Sprintf(`
+profiles:
+  logs:
+    root: %s
+    list: true
+  tmp:
+    root: %s
+    list: true
+`, logsDir, tmpDir))
+
+	profiles, err := loadProfiles(path)
+	assert.Nil(t, err)
+
+	t.Run("JSON", func(t *testing.T) {
+		var buffer bytes.Buffer
+		base := config{root: ".", workers: 1, format: formatJSON}
+
+		assert.Nil(t, runProfiles(&buffer, base, "", profiles, ""))
+
+		var doc struct {
+			Files   []fileRecord `json:"files"`
+			Summary summary      `json:"summary"`
+		}
+		assert.Nil(t, json.Unmarshal(buffer.Bytes(), &doc))
+
+		var names []string
+		for _, f := range doc.Files {
+			names = append(names, f.Profile)
+		}
+		assert.Equal(t, []string{"logs", "logs", "tmp"}, names)
+		assert.Equal(t, 3, doc.Summary.FilesMatched)
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		var buffer bytes.Buffer
+		base := config{root: ".", workers: 1, format: formatNDJSON}
+
+		assert.Nil(t, runProfiles(&buffer, base, "", profiles, ""))
+
+		lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		assert.Len(t, lines, 4)
+		assert.Contains(t, lines[2], `"profile":"tmp"`)
+		assert.Contains(t, lines[3], `"type":"summary"`)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		var buffer bytes.Buffer
+		base := config{root: ".", workers: 1, format: formatCSV}
+
+		assert.Nil(t, runProfiles(&buffer, base, "", profiles, ""))
+
+		rows, err := csv.NewReader(&buffer).ReadAll()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"type", "path", "size", "mode", "modTime",
+			"action", "profile"}, rows[0])
+		assert.Equal(t, "logs", rows[1][6])
+		assert.Equal(t, "tmp", rows[3][6])
+		assert.Equal(t, []string{"summary", "filesMatched", "3", "", "", "",
+			""}, rows[6])
+	})
+}
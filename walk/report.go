@@ -0,0 +1,401 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// supported output formats
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+// fileRecord describes a matched file and the action taken on it
+type fileRecord struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"modTime"`
+	Action  string    `json:"action"`
+	// destination of the file if it's a symbolic link
+	Link string `json:"link,omitempty"`
+	// name of the profile that matched the file, when running profiles
+	Profile string `json:"profile,omitempty"`
+}
+
+func newRecord(path string, info fs.FileInfo, action string) fileRecord {
+	if action == "" {
+		action = actionNone
+	}
+
+	return fileRecord{
+		Path:    path,
+		Size:    info.Size(),
+		Mode:    info.Mode().String(),
+		ModTime: info.ModTime(),
+		Action:  action,
+		Link:    linkTarget(path, info),
+	}
+}
+
+// summary holds the totals of a run
+type summary struct {
+	FilesScanned  int   `json:"filesScanned"`
+	DirsVisited   int   `json:"dirsVisited"`
+	FilesMatched  int   `json:"filesMatched"`
+	BytesMatched  int64 `json:"bytesMatched"`
+	FilesArchived int   `json:"filesArchived"`
+	FilesTrashed  int   `json:"filesTrashed"`
+	FilesMoved    int   `json:"filesMoved"`
+	FilesDeleted  int   `json:"filesDeleted"`
+	BytesDeleted  int64 `json:"bytesDeleted"`
+	Errors        int   `json:"errors"`
+	DryRun        bool  `json:"dryRun"`
+	// totals of the matched files by extension
+	Extensions map[string]*extSummary `json:"extensions"`
+}
+
+// extSummary holds the totals of the matched files with an extension
+type extSummary struct {
+	FilesMatched int   `json:"filesMatched"`
+	BytesMatched int64 `json:"bytesMatched"`
+}
+
+func newSummary(dryRun bool) *summary {
+	return &summary{DryRun: dryRun, Extensions: map[string]*extSummary{}}
+}
+
+// visit counts a file or directory seen by the walk, before filtering
+func (s *summary) visit(info fs.FileInfo) {
+	if info.IsDir() {
+		s.DirsVisited++
+		return
+	}
+
+	s.FilesScanned++
+}
+
+// add counts a matched file and the actions taken on it
+func (s *summary) add(r fileRecord) {
+	s.FilesMatched++
+	s.BytesMatched += r.Size
+
+	ext := filepath.Ext(r.Path)
+	if s.Extensions[ext] == nil {
+		s.Extensions[ext] = &extSummary{}
+	}
+	s.Extensions[ext].FilesMatched++
+	s.Extensions[ext].BytesMatched += r.Size
+
+	for _, action := range strings.Split(r.Action, "+") {
+		switch action {
+		case actionArchive:
+			s.FilesArchived++
+		case actionTrash:
+			s.FilesTrashed++
+		case actionMove:
+			s.FilesMoved++
+		case actionDelete:
+			s.FilesDeleted++
+			s.BytesDeleted += r.Size
+		}
+	}
+}
+
+// addError counts the errors aggregated in 'err'
+func (s *summary) addError(err error) {
+	if errs, ok := err.(actionErrors); ok {
+		s.Errors += len(errs)
+		return
+	}
+
+	s.Errors++
+}
+
+// merge adds the totals of 'other' to the summary
+func (s *summary) merge(other summary) {
+	s.FilesScanned += other.FilesScanned
+	s.DirsVisited += other.DirsVisited
+	s.FilesMatched += other.FilesMatched
+	s.BytesMatched += other.BytesMatched
+	s.FilesArchived += other.FilesArchived
+	s.FilesTrashed += other.FilesTrashed
+	s.FilesMoved += other.FilesMoved
+	s.FilesDeleted += other.FilesDeleted
+	s.BytesDeleted += other.BytesDeleted
+	s.Errors += other.Errors
+
+	for ext, e := range other.Extensions {
+		if s.Extensions[ext] == nil {
+			s.Extensions[ext] = &extSummary{}
+		}
+		s.Extensions[ext].FilesMatched += e.FilesMatched
+		s.Extensions[ext].BytesMatched += e.BytesMatched
+	}
+}
+
+// exts returns the extensions of the matched files in sorted order
+func (s summary) exts() []string {
+	exts := make([]string, 0, len(s.Extensions))
+	for ext := range s.Extensions {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+
+	return exts
+}
+
+// fields returns the summary as name and value pairs, for formats that
+// can't nest records. Totals by extension are named after the extension,
+// e.g. 'filesMatched[.log]'.
+func (s summary) fields() [][2]string {
+	fields := [][2]string{
+		{"filesScanned", strconv.Itoa(s.FilesScanned)},
+		{"dirsVisited", strconv.Itoa(s.DirsVisited)},
+		{"filesMatched", strconv.Itoa(s.FilesMatched)},
+		{"bytesMatched", strconv.FormatInt(s.BytesMatched, 10)},
+		{"filesArchived", strconv.Itoa(s.FilesArchived)},
+		{"filesTrashed", strconv.Itoa(s.FilesTrashed)},
+		{"filesMoved", strconv.Itoa(s.FilesMoved)},
+		{"filesDeleted", strconv.Itoa(s.FilesDeleted)},
+		{"bytesDeleted", strconv.FormatInt(s.BytesDeleted, 10)},
+		{"errors", strconv.Itoa(s.Errors)},
+		{"dryRun", strconv.FormatBool(s.DryRun)},
+	}
+
+	for _, ext := range s.exts() {
+		e := s.Extensions[ext]
+		fields = append(fields,
+			[2]string{fmt.Sprintf("filesMatched[%s]", ext),
+				strconv.Itoa(e.FilesMatched)},
+			[2]string{fmt.Sprintf("bytesMatched[%s]", ext),
+				strconv.FormatInt(e.BytesMatched, 10)})
+	}
+
+	return fields
+}
+
+// formatSize returns 'size' in bytes using the largest binary unit that keeps
+// the value above 1, e.g. 1.5KiB
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// reporter writes the matched files and the final summary in one of the
+// supported output formats. Reporters aren't safe for concurrent use, the
+// records must be reported in walk order.
+type reporter interface {
+	report(r fileRecord) error
+	summarize(s summary) error
+}
+
+// newReporter returns the reporter for the output format, or the one shared
+// by the profiles if set
+func newReporter(out io.Writer, cfg config) reporter {
+	if cfg.reporter != nil {
+		return cfg.reporter
+	}
+
+	switch cfg.format {
+	case formatJSON:
+		return &jsonReporter{out: out}
+	case formatCSV:
+		return &csvReporter{w: csv.NewWriter(out)}
+	case formatNDJSON:
+		return &ndjsonReporter{enc: json.NewEncoder(out)}
+	default:
+		return &textReporter{out: out, summary: cfg.summary}
+	}
+}
+
+// textReporter prints the path of the listed files, marking the symbolic
+// links with their destination, and, if requested, a table with the summary
+type textReporter struct {
+	out     io.Writer
+	summary bool
+}
+
+func (tr *textReporter) report(r fileRecord) error {
+	if r.Action != actionList {
+		return nil
+	}
+
+	if r.Link != "" {
+		return listFile(fmt.Sprintf("%s -> %s", r.Path, r.Link), tr.out)
+	}
+
+	return listFile(r.Path, tr.out)
+}
+
+func (tr *textReporter) summarize(s summary) error {
+	if !tr.summary {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(tr.out, 0, 0, 2, ' ', 0)
+
+	title := "SUMMARY"
+	if s.DryRun {
+		title += " (dry run)"
+	}
+	fmt.Fprintln(w, title)
+	fmt.Fprintf(w, "Files scanned:\t%d\n", s.FilesScanned)
+	fmt.Fprintf(w, "Directories visited:\t%d\n", s.DirsVisited)
+	fmt.Fprintf(w, "Files matched:\t%d\t%s\n", s.FilesMatched,
+		formatSize(s.BytesMatched))
+	fmt.Fprintf(w, "Files archived:\t%d\n", s.FilesArchived)
+	fmt.Fprintf(w, "Files trashed:\t%d\n", s.FilesTrashed)
+	fmt.Fprintf(w, "Files moved:\t%d\n", s.FilesMoved)
+	fmt.Fprintf(w, "Files deleted:\t%d\t%s\n", s.FilesDeleted,
+		formatSize(s.BytesDeleted))
+	fmt.Fprintf(w, "Errors:\t%d\n", s.Errors)
+
+	for _, ext := range s.exts() {
+		e := s.Extensions[ext]
+		if ext == "" {
+			ext = "(none)"
+		}
+		fmt.Fprintf(w, "  %s\t%d\t%s\n", ext, e.FilesMatched,
+			formatSize(e.BytesMatched))
+	}
+
+	return w.Flush()
+}
+
+// jsonReporter writes a single JSON document holding the list of files and
+// the summary. Files are streamed as they're reported instead of being kept
+// in memory.
+type jsonReporter struct {
+	out     io.Writer
+	started bool
+}
+
+func (jr *jsonReporter) start() error {
+	sep := ","
+	if !jr.started {
+		sep = `{"files":[`
+		jr.started = true
+	}
+
+	_, err := io.WriteString(jr.out, sep)
+	return err
+}
+
+func (jr *jsonReporter) report(r fileRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if err := jr.start(); err != nil {
+		return err
+	}
+
+	_, err = jr.out.Write(data)
+	return err
+}
+
+func (jr *jsonReporter) summarize(s summary) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	if !jr.started {
+		if err := jr.start(); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(jr.out, `],"summary":%s}`+"\n", data)
+	return err
+}
+
+// ndjsonReporter writes one JSON object per line. The 'type' field tells the
+// file records apart from the summary.
+type ndjsonReporter struct {
+	enc *json.Encoder
+}
+
+func (nr *ndjsonReporter) report(r fileRecord) error {
+	return nr.enc.Encode(struct {
+		Type string `json:"type"`
+		fileRecord
+	}{"file", r})
+}
+
+func (nr *ndjsonReporter) summarize(s summary) error {
+	return nr.enc.Encode(struct {
+		Type string `json:"type"`
+		summary
+	}{"summary", s})
+}
+
+// csvReporter writes a row per file followed by a row per summary value.
+// Summary rows have the 'summary' type, with the name of the value in the
+// 'path' column and the value itself in the 'size' column. When running
+// profiles, a last column holds the name of the profile that matched the file.
+type csvReporter struct {
+	w        *csv.Writer
+	profiles bool
+	started  bool
+}
+
+func (cr *csvReporter) write(row []string, profile string) error {
+	if !cr.started {
+		cr.started = true
+		header := []string{"type", "path", "size", "mode", "modTime", "action"}
+		if cr.profiles {
+			header = append(header, "profile")
+		}
+		if err := cr.w.Write(header); err != nil {
+			return err
+		}
+	}
+
+	if cr.profiles {
+		row = append(row, profile)
+	}
+
+	return cr.w.Write(row)
+}
+
+func (cr *csvReporter) report(r fileRecord) error {
+	return cr.write([]string{"file", r.Path, strconv.FormatInt(r.Size, 10),
+		r.Mode, r.ModTime.Format(time.RFC3339), r.Action}, r.Profile)
+}
+
+func (cr *csvReporter) summarize(s summary) error {
+	for _, field := range s.fields() {
+		if err := cr.write([]string{"summary", field[0], field[1], "", "",
+			""}, ""); err != nil {
+			return err
+		}
+	}
+
+	cr.w.Flush()
+	return cr.w.Error()
+}
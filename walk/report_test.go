@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunFormatJSON(t *testing.T) {
+	testCases := []struct {
+		testName string
+		cfg      config
+		expected []string
+	}{
+		{testName: "ListAll", cfg: config{list: true},
+			expected: []string{"testdata/dir.log", "testdata/dir2/script.sh"}},
+		{testName: "ListExtension", cfg: config{ext: []string{".sh"},
+			list: true}, expected: []string{"testdata/dir2/script.sh"}},
+		{testName: "NoMatch", cfg: config{ext: []string{".gz"}, list: true},
+			expected: []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			tc.cfg.root = "testdata"
+			tc.cfg.format = formatJSON
+			assert.Nil(t, run(&buffer, tc.cfg))
+
+			var result struct {
+				Files   []fileRecord
+				Summary summary
+			}
+			assert.Nil(t, json.Unmarshal(buffer.Bytes(), &result))
+
+			paths := []string{}
+			for _, r := range result.Files {
+				paths = append(paths, r.Path)
+				assert.Equal(t, actionList, r.Action)
+			}
+			assert.Equal(t, tc.expected, paths)
+			assert.Equal(t, len(tc.expected), result.Summary.FilesMatched)
+		})
+	}
+}
+
+func TestRunFormatNDJSON(t *testing.T) {
+	var buffer bytes.Buffer
+
+	cfg := config{root: "testdata", format: formatNDJSON}
+	assert.Nil(t, run(&buffer, cfg))
+
+	types := []string{}
+	s := bufio.NewScanner(&buffer)
+	for s.Scan() {
+		var record struct {
+			Type   string
+			Path   string
+			Size   int64
+			Action string
+		}
+		assert.Nil(t, json.Unmarshal(s.Bytes(), &record))
+		types = append(types, record.Type)
+
+		if record.Path == "testdata/dir.log" {
+			assert.Equal(t, int64(12), record.Size)
+			assert.Equal(t, actionNone, record.Action)
+		}
+	}
+
+	assert.Equal(t, []string{"file", "file", "summary"}, types)
+}
+
+func TestRunFormatCSV(t *testing.T) {
+	var buffer bytes.Buffer
+
+	cfg := config{root: "testdata", ext: []string{".log"}, list: true,
+		format: formatCSV}
+	assert.Nil(t, run(&buffer, cfg))
+
+	rows, err := csv.NewReader(&buffer).ReadAll()
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"type", "path", "size", "mode", "modTime",
+		"action"}, rows[0])
+	assert.Equal(t, []string{"file", "testdata/dir.log", "12"}, rows[1][:3])
+	assert.Equal(t, actionList, rows[1][5])
+
+	totals := map[string]string{}
+	for _, row := range rows[2:] {
+		assert.Equal(t, "summary", row[0])
+		totals[row[1]] = row[2]
+	}
+	assert.Equal(t, "2", totals["filesScanned"])
+	assert.Equal(t, "1", totals["filesMatched"])
+	assert.Equal(t, "12", totals["bytesMatched"])
+	assert.Equal(t, "1", totals["filesMatched[.log]"])
+}
+
+func TestRunFormatWorkers(t *testing.T) {
+	var expected, result bytes.Buffer
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 20, ".gz": 20})
+	defer cleanup()
+
+	cfg := config{root: tempDir, ext: []string{".log"}, list: true,
+		format: formatNDJSON}
+	assert.Nil(t, run(&expected, cfg))
+
+	cfg.workers = 4
+	assert.Nil(t, run(&result, cfg))
+
+	assert.Equal(t, expected.String(), result.String())
+}
+
+func TestRunSummary(t *testing.T) {
+	var (
+		buffer    bytes.Buffer
+		logBuffer bytes.Buffer
+	)
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 5, ".gz": 3,
+		".tmp": 2})
+	defer cleanup()
+
+	archiveDir, cleanupArchive := createTempDir(t, nil)
+	defer cleanupArchive()
+
+	cfg := config{root: tempDir, ext: []string{".log", ".tmp"},
+		archive: archiveDir, del: true, format: formatJSON, wLog: &logBuffer}
+	assert.Nil(t, run(&buffer, cfg))
+
+	var result struct {
+		Summary summary
+	}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &result))
+
+	// every dummy file holds 5 bytes
+	expected := summary{
+		FilesScanned:  10,
+		DirsVisited:   1,
+		FilesMatched:  7,
+		BytesMatched:  35,
+		FilesArchived: 7,
+		FilesDeleted:  7,
+		BytesDeleted:  35,
+		Extensions: map[string]*extSummary{
+			".log": {FilesMatched: 5, BytesMatched: 25},
+			".tmp": {FilesMatched: 2, BytesMatched: 10},
+		},
+	}
+	assert.Equal(t, expected, result.Summary)
+}
+
+func TestRunSummaryText(t *testing.T) {
+	var buffer bytes.Buffer
+
+	cfg := config{root: "testdata", list: true, summary: true}
+	assert.Nil(t, run(&buffer, cfg))
+
+	result := buffer.String()
+	assert.True(t, strings.HasPrefix(result,
+		"testdata/dir.log\ntestdata/dir2/script.sh\nSUMMARY\n"))
+	assert.Contains(t, result, "Files scanned:        2\n")
+	assert.Contains(t, result, "Directories visited:  2\n")
+	assert.Contains(t, result, "Files matched:        2  12B\n")
+	assert.Contains(t, result, "  .log                1  12B\n")
+}
+
+func TestRunSummaryErrors(t *testing.T) {
+	var buffer bytes.Buffer
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 3})
+	defer cleanup()
+
+	// archiving into a file instead of a directory fails for every file
+	archive := filepath.Join(tempDir, "archive")
+	assert.Nil(t, os.WriteFile(archive, []byte{}, 0644))
+
+	cfg := config{root: tempDir, ext: []string{".log"}, archive: archive,
+		workers: 2, format: formatJSON, wLog: &bytes.Buffer{}}
+	assert.NotNil(t, run(&buffer, cfg))
+
+	var result struct {
+		Summary summary
+	}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &result))
+	assert.Equal(t, 3, result.Summary.Errors)
+	assert.Equal(t, 0, result.Summary.FilesMatched)
+}
+
+func TestFormatSize(t *testing.T) {
+	testCases := []struct {
+		size     int64
+		expected string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1.0KiB"},
+		{1536, "1.5KiB"},
+		{5 * 1024 * 1024, "5.0MiB"},
+		{3 * 1024 * 1024 * 1024, "3.0GiB"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatSize(tc.size))
+		})
+	}
+}
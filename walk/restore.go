@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// restoreFiles replays the delete log given by 'cfg.restore', putting every
+// deleted or trashed file back at its original path using the copy found in
+// the archive directory or the trash. The archived copies are found from the
+// log, so it can be replayed from any directory. Files that cannot be
+// recovered are reported and counted, but don't stop the remaining files
+// from being restored.
+func restoreFiles(out io.Writer, cfg config) error {
+	f, err := os.Open(cfg.restore)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// index the trash by original path, most recent deletion last
+	trashed := map[string][]trashInfo{}
+	if cfg.trashDir != "" {
+		entries, err := listTrash(cfg.trashDir)
+		if err != nil {
+			return err
+		}
+
+		for _, info := range entries {
+			trashed[info.path] = append(trashed[info.path], info)
+		}
+	}
+
+	// archived copies by original path, logged before the files are deleted
+	archived := map[string]string{}
+
+	failed := 0
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if entry, ok := parseLogLine(s.Text(), archLogPrefix); ok {
+			if i := strings.LastIndex(entry, " -> "); i >= 0 {
+				archived[entry[:i]] = entry[i+len(" -> "):]
+			}
+			continue
+		}
+
+		path, ok := parseLogLine(s.Text(), delLogPrefix)
+		if !ok {
+			path, ok = parseLogLine(s.Text(), trashLogPrefix)
+		}
+		if !ok {
+			continue
+		}
+
+		if err := restorePath(path, cfg, archived, trashed); err != nil {
+			failed++
+			fmt.Fprintf(out, "NOT RESTORED: %s: %s\n", path, err)
+			continue
+		}
+
+		if cfg.dryRun {
+			fmt.Fprint(out, dryRunPrefix)
+		}
+		fmt.Fprintf(out, "RESTORED FILE: %s\n", path)
+	}
+
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return ErrNotRestored.Errorf(failed)
+	}
+
+	return nil
+}
+
+// restorePath restores a single file, trying its archived copy first and
+// then the most recent trash entry for the same path. Logs that don't
+// record the archived copies are resolved against the root.
+func restorePath(path string, cfg config, archived map[string]string,
+	trashed map[string][]trashInfo) error {
+	err := fmt.Errorf("no archived or trashed copy found")
+	abs := absPath(path)
+
+	src, ok := archived[abs]
+	if !ok && cfg.archive != "" {
+		src, err = archivedCopy(cfg.archive, absPath(cfg.root), abs)
+		ok = err == nil
+	}
+
+	if ok {
+		err = restoreFile(src, abs, cfg.dryRun)
+		if err == nil {
+			return nil
+		}
+	}
+
+	entries := trashed[abs]
+	if len(entries) == 0 {
+		return err
+	}
+
+	info := entries[len(entries)-1]
+	if err := restoreFromTrash(cfg.trashDir, info, cfg.dryRun); err != nil {
+		return err
+	}
+
+	// the entry is gone, older ones can still be restored
+	trashed[abs] = entries[:len(entries)-1]
+	return nil
+}
+
+// parseLogLine extracts the file path from a line written by a logger using
+// 'prefix' and the standard date and time flags.
+func parseLogLine(line, prefix string) (string, bool) {
+	if !strings.HasPrefix(line, prefix) {
+		return "", false
+	}
+
+	// skip the date and time fields written by log.LstdFlags
+	fields := strings.SplitN(strings.TrimPrefix(line, prefix), " ", 3)
+	if len(fields) != 3 || fields[2] == "" {
+		return "", false
+	}
+
+	return fields[2], true
+}
+
+// restoreFile decompresses the archived copy 'src' made by archiveFile back
+// to 'path', with its original permissions and modification time. The file
+// is written next to 'path' and only linked into place once complete.
+// Existing files are never overwritten.
+func restoreFile(src, path string, dryRun bool) error {
+	in, err := os.Open(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no archived copy found at %s", src)
+		}
+		return err
+	}
+	defer in.Close()
+
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%s: %w", path, os.ErrExist)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(path),
+		fmt.Sprintf(".%s.walk-restore", filepath.Base(path)))
+	os.Remove(tmp)
+
+	if err := writeRestored(tmp, zr); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// linking fails if the file was created in the meantime
+	err = os.Link(tmp, path)
+	os.Remove(tmp)
+	return err
+}
+
+// writeRestored writes the contents of the archive to the new file 'path',
+// applying the permissions and modification time it recorded
+func writeRestored(path string, zr *gzip.Reader) error {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, zr); err != nil {
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	// archives written before the permissions were recorded get the default
+	mode := fs.FileMode(0644)
+	if strings.HasPrefix(zr.Comment, archiveModePrefix) {
+		if mode, err = parseOctalMode(strings.TrimPrefix(zr.Comment,
+			archiveModePrefix)); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+
+	if !zr.ModTime.IsZero() {
+		return os.Chtimes(path, zr.ModTime, zr.ModTime)
+	}
+
+	return nil
+}
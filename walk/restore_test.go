@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLogLine(t *testing.T) {
+	testCases := []struct {
+		testName string
+		line     string
+		path     string
+		ok       bool
+	}{
+		{"ValidLine", "DELETED FILE: 2022/04/04 10:00:00 /tmp/file.log",
+			"/tmp/file.log", true},
+		{"PathWithSpaces", "DELETED FILE: 2022/04/04 10:00:00 /tmp/a file.log",
+			"/tmp/a file.log", true},
+		{"WrongPrefix", "ARCHIVED FILE: 2022/04/04 10:00:00 /tmp/file.log",
+			"", false},
+		{"MissingPath", "DELETED FILE: 2022/04/04 10:00:00", "", false},
+		{"EmptyLine", "", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			path, ok := parseLogLine(tc.line, delLogPrefix)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.path, path)
+		})
+	}
+}
+
+func TestRestoreFiles(t *testing.T) {
+	var (
+		buffer    bytes.Buffer
+		logBuffer bytes.Buffer
+	)
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 5, ".gz": 5})
+	defer cleanup()
+
+	archiveDir, cleanupArchive := createTempDir(t, nil)
+	defer cleanupArchive()
+
+	cfg := config{root: tempDir, ext: []string{".log"}, archive: archiveDir,
+		del: true, wLog: &logBuffer}
+	assert.Nil(t, run(&buffer, cfg))
+
+	// write the delete log to disk and add an entry with no archived copy
+	missing := filepath.Join(tempDir, "missing.log")
+	logFile := filepath.Join(archiveDir, "deleted.log")
+	logBuffer.WriteString(fmt.Sprintf("%s2022/04/04 10:00:00 %s\n",
+		delLogPrefix, missing))
+	assert.Nil(t, os.WriteFile(logFile, logBuffer.Bytes(), 0644))
+
+	// a dry run reports the files without restoring them
+	cfg.restore = logFile
+	cfg.dryRun = true
+	buffer.Reset()
+	err := restoreFiles(&buffer, cfg)
+	assert.Equal(t, ErrNotRestored.Errorf(1), err)
+	assert.Equal(t, 5, strings.Count(buffer.String(), dryRunPrefix))
+
+	restored, err := filepath.Glob(filepath.Join(tempDir, "*.log"))
+	assert.Nil(t, err)
+	assert.Empty(t, restored)
+
+	cfg.dryRun = false
+	buffer.Reset()
+	err = restoreFiles(&buffer, cfg)
+	assert.Equal(t, ErrNotRestored.Errorf(1), err)
+
+	restored, err = filepath.Glob(filepath.Join(tempDir, "*.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(restored))
+
+	for _, path := range restored {
+		data, err := os.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "dummy", string(data))
+	}
+
+	result := buffer.String()
+	assert.Equal(t, 5, strings.Count(result, "RESTORED FILE: "))
+	assert.Contains(t, result, fmt.Sprintf("NOT RESTORED: %s", missing))
+
+	// restoring again must not overwrite the files already in place
+	buffer.Reset()
+	err = restoreFiles(&buffer, cfg)
+	assert.Equal(t, ErrNotRestored.Errorf(6), err)
+}
+
+func TestRestoreFilesElsewhere(t *testing.T) {
+	testCases := []struct {
+		testName string
+		cfg      config
+	}{
+		{testName: "Archive", cfg: config{del: true}},
+		{testName: "Trash", cfg: config{trash: true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer, logBuffer bytes.Buffer
+
+			tempDir, cleanup := createTempDir(t, map[string]int{".log": 3})
+			defer cleanup()
+
+			backupDir, cleanupBackup := createTempDir(t, nil)
+			defer cleanupBackup()
+
+			// the files are deleted using a root relative to this directory
+			wd, err := os.Getwd()
+			assert.Nil(t, err)
+			root, err := filepath.Rel(wd, tempDir)
+			assert.Nil(t, err)
+
+			tc.cfg.root = root
+			tc.cfg.wLog = &logBuffer
+			if tc.cfg.trash {
+				tc.cfg.trashDir = backupDir
+			} else {
+				tc.cfg.archive = backupDir
+			}
+			assert.Nil(t, run(&buffer, tc.cfg))
+
+			logFile := filepath.Join(backupDir, "deleted.log")
+			assert.Nil(t, os.WriteFile(logFile, logBuffer.Bytes(), 0644))
+
+			// and restored from another directory, with the default root
+			assert.Nil(t, os.Chdir(backupDir))
+			defer os.Chdir(wd)
+
+			cfg := config{root: ".", restore: logFile,
+				trashDir: tc.cfg.trashDir}
+			buffer.Reset()
+			assert.Nil(t, restoreFiles(&buffer, cfg))
+			assert.Equal(t, 3, strings.Count(buffer.String(),
+				"RESTORED FILE: "))
+
+			restored, err := filepath.Glob(filepath.Join(tempDir, "*.log"))
+			assert.Nil(t, err)
+			assert.Equal(t, 3, len(restored))
+		})
+	}
+}
+
+func TestRestoreFileMode(t *testing.T) {
+	var buffer, logBuffer bytes.Buffer
+
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	archiveDir, cleanupArchive := createTempDir(t, nil)
+	defer cleanupArchive()
+
+	path := filepath.Join(tempDir, "secret.txt")
+	assert.Nil(t, os.WriteFile(path, []byte("secret"), 0600))
+	assert.Nil(t, os.Chmod(path, 0640))
+
+	cfg := config{root: tempDir, archive: archiveDir, del: true,
+		wLog: &logBuffer}
+	assert.Nil(t, run(&buffer, cfg))
+
+	logFile := filepath.Join(archiveDir, "deleted.log")
+	assert.Nil(t, os.WriteFile(logFile, logBuffer.Bytes(), 0644))
+
+	cfg.restore = logFile
+	assert.Nil(t, restoreFiles(&buffer, cfg))
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func TestRestoreFilePartial(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	path := filepath.Join(tempDir, "app.log")
+	assert.Nil(t, os.WriteFile(path, []byte("a longer line of log"), 0644))
+	info, err := os.Lstat(path)
+	assert.Nil(t, err)
+	src, err := archiveFile(tempDir, tempDir, path, info, false)
+	assert.Nil(t, err)
+	assert.Nil(t, os.Remove(path))
+
+	// a truncated archive fails partway without leaving anything behind
+	data, err := os.ReadFile(src)
+	assert.Nil(t, err)
+	truncated := filepath.Join(tempDir, "truncated.gz")
+	assert.Nil(t, os.WriteFile(truncated, data[:len(data)-8], 0644))
+
+	assert.NotNil(t, restoreFile(truncated, path, false))
+	entries, err := os.ReadDir(tempDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	// so the restore can be tried again
+	assert.Nil(t, restoreFile(src, path, false))
+	data, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "a longer line of log", string(data))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+// newestFiles returns the paths of the 'cfg.keepLast' most recently
+// modified matched files of every directory, which are exempt from the
+// actions. Files modified at the same time are ordered by path.
+func newestFiles(cfg config) (map[string]bool, error) {
+	// the files kept by a previous call must be counted again
+	cfg.kept = nil
+
+	byDir := map[string][]fileEntry{}
+	err := walkFiles(cfg, nil, func(path string, info fs.FileInfo) error {
+		if !info.IsDir() {
+			dir := filepath.Dir(path)
+			byDir[dir] = append(byDir[dir], fileEntry{path, info})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	kept := map[string]bool{}
+	for _, files := range byDir {
+		sort.Slice(files, func(i, j int) bool {
+			ti, tj := files[i].info.ModTime(), files[j].info.ModTime()
+			if !ti.Equal(tj) {
+				return ti.After(tj)
+			}
+			return files[i].path < files[j].path
+		})
+
+		for i := 0; i < cfg.keepLast && i < len(files); i++ {
+			kept[files[i].path] = true
+		}
+	}
+
+	return kept, nil
+}
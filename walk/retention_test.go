@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunKeepLast(t *testing.T) {
+	mtime := time.Date(2022, 5, 1, 10, 0, 0, 0, time.Local)
+
+	tempDir, cleanup := createTree(t, map[string]string{
+		"backups/db-1.bak":  "1",
+		"backups/db-2.bak":  "2",
+		"backups/db-3.bak":  "3",
+		"backups/db-4.bak":  "4",
+		"backups/notes.txt": "not matched",
+		"other/web-1.bak":   "1",
+	}, mtime)
+	defer cleanup()
+
+	// the backups are a day apart, the notes are the newest file
+	for i := 1; i <= 4; i++ {
+		path := filepath.Join(tempDir, "backups", fmt.Sprintf("db-%d.bak", i))
+		day := mtime.AddDate(0, 0, i)
+		assert.Nil(t, os.Chtimes(path, day, day))
+	}
+	notes := filepath.Join(tempDir, "backups", "notes.txt")
+	assert.Nil(t, os.Chtimes(notes, mtime.AddDate(0, 1, 0),
+		mtime.AddDate(0, 1, 0)))
+
+	testCases := []struct {
+		testName string
+		keepLast int
+		deleted  []string
+	}{
+		{testName: "KeepAll", keepLast: 5},
+		{testName: "KeepTwo", keepLast: 2,
+			deleted: []string{"backups/db-1.bak", "backups/db-2.bak"}},
+		{testName: "KeepNone", keepLast: 0,
+			deleted: []string{"backups/db-1.bak", "backups/db-2.bak",
+				"backups/db-3.bak", "backups/db-4.bak", "other/web-1.bak"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			cfg := config{root: tempDir, ext: []string{".bak"}, del: true,
+				keepLast: tc.keepLast, dryRun: true, workers: 1,
+				wLog: &buffer}
+			assert.Nil(t, execute(&buffer, cfg, ""))
+
+			deleted := []string{}
+			for _, line := range strings.Split(buffer.String(), "\n") {
+				if strings.HasPrefix(line, dryRunPrefix+delLogPrefix) {
+					// the path follows the date and time
+					fields := strings.Fields(line)
+					deleted = append(deleted,
+						relPath(tempDir, fields[len(fields)-1]))
+				}
+			}
+			assert.ElementsMatch(t, tc.deleted, deleted)
+		})
+	}
+}
+
+func TestNewestFiles(t *testing.T) {
+	mtime := time.Date(2022, 5, 1, 10, 0, 0, 0, time.Local)
+
+	// files modified at the same time are kept by path
+	tempDir, cleanup := createTree(t, map[string]string{
+		"b.log":     "b",
+		"a.log":     "a",
+		"c.log":     "c",
+		"sub/d.log": "d",
+	}, mtime)
+	defer cleanup()
+
+	cfg := config{root: tempDir, keepLast: 2}
+	// files kept by a previous call are counted again
+	cfg.kept = map[string]bool{filepath.Join(tempDir, "a.log"): true}
+
+	kept, err := newestFiles(cfg)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{
+		filepath.Join(tempDir, "a.log"):        true,
+		filepath.Join(tempDir, "b.log"):        true,
+		filepath.Join(tempDir, "sub", "d.log"): true,
+	}, kept)
+}
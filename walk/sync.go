@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// syncFile copies the file at 'path' to the same location relative to the
+// root under 'cfg.sync', unless the copy already has the same size,
+// modification time and permissions. The copy keeps the permissions and
+// modification time of the original, and symbolic links are copied as
+// links. Other files that aren't regular, like named pipes, are skipped. It
+// reports whether the file was copied.
+func syncFile(path string, info fs.FileInfo, cfg config,
+	syncLogger *log.Logger) (bool, error) {
+	rel := relPath(cfg.root, path)
+	dst := filepath.Join(cfg.sync, filepath.FromSlash(rel))
+
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		return syncLink(path, dst, cfg.dryRun, syncLogger)
+	case !info.Mode().IsRegular():
+		return false, nil
+	}
+
+	dstInfo, err := os.Lstat(dst)
+	switch {
+	case err == nil && sameFileInfo(info, dstInfo):
+		return false, nil
+	case err != nil && !os.IsNotExist(err):
+		return false, err
+	}
+
+	if !cfg.dryRun {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return false, err
+		}
+
+		// copy next to the destination and rename it over the old copy, so
+		// the destination is never left half written
+		tmp := filepath.Join(filepath.Dir(dst),
+			fmt.Sprintf(".%s.walk-sync", filepath.Base(dst)))
+		os.Remove(tmp)
+
+		if err := copyFile(path, tmp); err != nil {
+			os.Remove(tmp)
+			return false, err
+		}
+
+		// the umask may have removed some permissions
+		if err := os.Chmod(tmp, info.Mode()&permBits); err != nil {
+			os.Remove(tmp)
+			return false, err
+		}
+
+		if err := os.Rename(tmp, dst); err != nil {
+			os.Remove(tmp)
+			return false, err
+		}
+	}
+
+	syncLogger.Printf("%s -> %s", path, dst)
+	return true, nil
+}
+
+// syncLink makes 'dst' a symbolic link with the same target as 'path'
+func syncLink(path, dst string, dryRun bool,
+	syncLogger *log.Logger) (bool, error) {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return false, err
+	}
+
+	if current, err := os.Readlink(dst); err == nil && current == target {
+		return false, nil
+	}
+
+	if !dryRun {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return false, err
+		}
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		if err := os.Symlink(target, dst); err != nil {
+			return false, err
+		}
+	}
+
+	syncLogger.Printf("%s -> %s", path, dst)
+	return true, nil
+}
+
+// sameFileInfo reports whether a copy is up to date with the original
+func sameFileInfo(a, b fs.FileInfo) bool {
+	return a.Mode() == b.Mode() && a.Size() == b.Size() &&
+		sameModTime(a.ModTime(), b.ModTime())
+}
+
+// pruneSync deletes the files under 'cfg.sync' that match the filters but
+// weren't synced, i.e. whose relative path isn't in 'synced'. Files the
+// filters leave out are never deleted. The deletions have their own log
+// prefix, the restore mode only replays the ones of the delete action.
+func pruneSync(cfg config, synced map[string]bool,
+	pruneLogger *log.Logger) error {
+	if _, err := os.Stat(cfg.sync); os.IsNotExist(err) {
+		return nil
+	}
+
+	destCfg := cfg
+	destCfg.root = cfg.sync
+
+	return walkFiles(destCfg, nil, func(path string, info fs.FileInfo) error {
+		if info.IsDir() || synced[relPath(cfg.sync, path)] {
+			return nil
+		}
+
+		return deleteFile(path, pruneLogger, cfg.dryRun)
+	})
+}
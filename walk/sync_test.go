@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSync(t *testing.T) {
+	mtime := time.Date(2022, 5, 1, 10, 0, 0, 0, time.Local)
+
+	src, cleanupSrc := createTree(t, map[string]string{
+		"a.txt":     "alpha",
+		"sub/b.txt": "bravo",
+		"c.other":   "charlie",
+	}, mtime)
+	defer cleanupSrc()
+	assert.Nil(t, os.Chmod(filepath.Join(src, "a.txt"), 0600))
+
+	dest, cleanupDest := createTree(t, map[string]string{
+		// already in sync
+		"sub/b.txt": "bravo",
+		// extraneous, but only the first one matches the filters
+		"old.txt":   "old",
+		"old.other": "old",
+	}, mtime)
+	defer cleanupDest()
+
+	testCases := []struct {
+		testName string
+		dryRun   bool
+		synced   int
+		deleted  int
+		exists   map[string]bool
+	}{
+		{testName: "DryRun", dryRun: true, synced: 1, deleted: 1,
+			exists: map[string]bool{"a.txt": false, "old.txt": true}},
+		{testName: "Sync", synced: 1, deleted: 1,
+			exists: map[string]bool{"a.txt": true, "old.txt": false}},
+		{testName: "AlreadySynced", synced: 0, deleted: 0,
+			exists: map[string]bool{"a.txt": true, "old.txt": false}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer, logBuffer bytes.Buffer
+
+			cfg := config{root: src, ext: []string{".txt"}, sync: dest,
+				syncDelete: true, dryRun: tc.dryRun, format: formatNDJSON,
+				wLog: &logBuffer}
+			assert.Nil(t, run(&buffer, cfg))
+
+			logs := logBuffer.String()
+			assert.Equal(t, tc.synced, strings.Count(logs, syncLogPrefix))
+			assert.Equal(t, tc.deleted, strings.Count(logs, pruneLogPrefix))
+			// restoring the deleted files doesn't replay the pruning
+			assert.Equal(t, 0, strings.Count(logs, delLogPrefix))
+			assert.Equal(t, tc.synced,
+				strings.Count(buffer.String(), `"action":"sync"`))
+
+			for name, exists := range tc.exists {
+				_, err := os.Stat(filepath.Join(dest, name))
+				assert.Equal(t, exists, err == nil, name)
+			}
+
+			// files left out by the filters are never touched
+			_, err := os.Stat(filepath.Join(dest, "old.other"))
+			assert.Nil(t, err)
+			_, err = os.Stat(filepath.Join(dest, "c.other"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+
+	// the copy keeps the permissions and modification time
+	info, err := os.Stat(filepath.Join(dest, "a.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.True(t, mtime.Equal(info.ModTime()))
+
+	data, err := os.ReadFile(filepath.Join(dest, "a.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "alpha", string(data))
+
+	// a changed file is copied again
+	assert.Nil(t, os.WriteFile(filepath.Join(src, "a.txt"), []byte("ALPHA!"),
+		0600))
+
+	var logBuffer bytes.Buffer
+	cfg := config{root: src, ext: []string{".txt"}, sync: dest,
+		wLog: &logBuffer}
+	assert.Nil(t, run(&bytes.Buffer{}, cfg))
+	assert.Equal(t, 1, strings.Count(logBuffer.String(), syncLogPrefix))
+
+	data, err = os.ReadFile(filepath.Join(dest, "a.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "ALPHA!", string(data))
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dest)
+	assert.Nil(t, err)
+	for _, entry := range entries {
+		assert.False(t, strings.HasSuffix(entry.Name(), ".walk-sync"))
+	}
+}
+
+func TestRunSyncInsideRoot(t *testing.T) {
+	mtime := time.Date(2022, 5, 1, 10, 0, 0, 0, time.Local)
+
+	src, cleanup := createTree(t, map[string]string{"a.txt": "alpha"}, mtime)
+	defer cleanup()
+
+	// the root is relative and the mirror inside it absolute
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	root, err := filepath.Rel(wd, src)
+	assert.Nil(t, err)
+	dest := filepath.Join(src, "mirror")
+
+	var logBuffer bytes.Buffer
+	cfg := config{root: root, sync: dest, wLog: &logBuffer}
+
+	// the copies are never synced again
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, run(&bytes.Buffer{}, cfg))
+	}
+
+	assert.Equal(t, 1, strings.Count(logBuffer.String(), syncLogPrefix))
+	_, err = os.Stat(filepath.Join(dest, "mirror"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// layout of the deletion date stored in the .trashinfo files
+const trashDateLayout = "2006-01-02T15:04:05"
+
+// trashInfo describes an entry of an XDG-style trash directory
+type trashInfo struct {
+	// name of the entry inside the 'files' and 'info' directories
+	name string
+	// original absolute path of the file
+	path string
+	// when the file was trashed
+	deleted time.Time
+}
+
+// defaultTrashDir returns the user's trash directory following the XDG
+// specification, or an empty string if it can't be determined.
+func defaultTrashDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "Trash")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".local", "share", "Trash")
+}
+
+// trashFile moves the file at 'path' into 'trashDir', writing a .trashinfo
+// file with its original path and the deletion time so it can be restored.
+func trashFile(trashDir, path string, trashLogger *log.Logger,
+	dryRun bool) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	// absolute paths let the files be restored from anywhere
+	if dryRun {
+		trashLogger.Println(abs)
+		return nil
+	}
+
+	filesDir := filepath.Join(trashDir, "files")
+	infoDir := filepath.Join(trashDir, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	name, err := writeTrashInfo(infoDir, abs, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := moveFile(path, filepath.Join(filesDir, name)); err != nil {
+		os.Remove(filepath.Join(infoDir, name+".trashinfo"))
+		return err
+	}
+
+	trashLogger.Println(abs)
+	return nil
+}
+
+// writeTrashInfo creates the .trashinfo file for 'path', picking a name that
+// isn't used by any other entry. Creating the file exclusively reserves the
+// name, even when several files are trashed concurrently.
+func writeTrashInfo(infoDir, path string, deleted time.Time) (string, error) {
+	base := filepath.Base(path)
+
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s.%d", base, n)
+		}
+
+		f, err := os.OpenFile(filepath.Join(infoDir, name+".trashinfo"),
+			os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		_, err = fmt.Fprintf(f, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+			(&url.URL{Path: path}).EscapedPath(), deleted.Format(trashDateLayout))
+		if err != nil {
+			f.Close()
+			return "", err
+		}
+
+		return name, f.Close()
+	}
+}
+
+// readTrashInfo parses the .trashinfo file for the entry 'name'
+func readTrashInfo(infoDir, name string) (trashInfo, error) {
+	info := trashInfo{name: name}
+
+	f, err := os.Open(filepath.Join(infoDir, name+".trashinfo"))
+	if err != nil {
+		return info, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.SplitN(s.Text(), "=", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		switch key, value := fields[0], fields[1]; key {
+		case "Path":
+			if info.path, err = url.PathUnescape(value); err != nil {
+				return info, err
+			}
+		case "DeletionDate":
+			info.deleted, err = time.ParseInLocation(trashDateLayout, value,
+				time.Local)
+			if err != nil {
+				return info, err
+			}
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return info, err
+	}
+
+	if info.path == "" {
+		return info, fmt.Errorf("%s: missing original path", name)
+	}
+
+	return info, nil
+}
+
+// listTrash returns the entries of 'trashDir' sorted by deletion time.
+// Entries with unreadable metadata are ignored.
+func listTrash(trashDir string) ([]trashInfo, error) {
+	infoDir := filepath.Join(trashDir, "info")
+
+	files, err := os.ReadDir(infoDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	entries := []trashInfo{}
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".trashinfo")
+		if name == file.Name() {
+			continue
+		}
+
+		info, err := readTrashInfo(infoDir, name)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, info)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].deleted.Before(entries[j].deleted)
+	})
+
+	return entries, nil
+}
+
+// restoreFromTrash moves a trashed file back to its original path and
+// removes its metadata. Existing files are never overwritten.
+func restoreFromTrash(trashDir string, info trashInfo, dryRun bool) error {
+	if _, err := os.Lstat(info.path); err == nil {
+		return fmt.Errorf("%s: %w", info.path, os.ErrExist)
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(info.path), 0755); err != nil {
+		return err
+	}
+
+	src := filepath.Join(trashDir, "files", info.name)
+	if err := moveFile(src, info.path); err != nil {
+		return err
+	}
+
+	return os.Remove(filepath.Join(trashDir, "info", info.name+".trashinfo"))
+}
+
+// emptyTrash permanently removes the entries of the trash directory. When
+// 'cfg.olderThan' is set, only files trashed before that time are removed.
+func emptyTrash(out io.Writer, cfg config) error {
+	purgeLogger := newLogger(out, cfg, purgeLogPrefix)
+
+	entries, err := listTrash(cfg.trashDir)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range entries {
+		if !cfg.olderThan.IsZero() && !info.deleted.Before(cfg.olderThan) {
+			continue
+		}
+
+		if !cfg.dryRun {
+			err := os.RemoveAll(filepath.Join(cfg.trashDir, "files", info.name))
+			if err != nil {
+				return err
+			}
+
+			infoFile := filepath.Join(cfg.trashDir, "info", info.name+".trashinfo")
+			if err := os.Remove(infoFile); err != nil {
+				return err
+			}
+		}
+
+		purgeLogger.Println(info.path)
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunTrash(t *testing.T) {
+	testCases := []struct {
+		testName string
+		cfg      config
+	}{
+		{testName: "Trash", cfg: config{ext: []string{".log"}, trash: true}},
+		{testName: "SafeDelete", cfg: config{ext: []string{".log"}, del: true,
+			safeDel: true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var (
+				buffer    bytes.Buffer
+				logBuffer bytes.Buffer
+			)
+
+			tempDir, cleanup := createTempDir(t, map[string]int{".log": 5,
+				".gz": 5})
+			defer cleanup()
+
+			trashDir, cleanupTrash := createTempDir(t, nil)
+			defer cleanupTrash()
+
+			tc.cfg.root = tempDir
+			tc.cfg.trashDir = trashDir
+			tc.cfg.wLog = &logBuffer
+			assert.Nil(t, run(&buffer, tc.cfg))
+
+			remaining, err := filepath.Glob(filepath.Join(tempDir, "*.log"))
+			assert.Nil(t, err)
+			assert.Empty(t, remaining)
+
+			trashed, err := os.ReadDir(filepath.Join(trashDir, "files"))
+			assert.Nil(t, err)
+			assert.Equal(t, 5, len(trashed))
+
+			entries, err := listTrash(trashDir)
+			assert.Nil(t, err)
+			assert.Equal(t, 5, len(entries))
+
+			for _, info := range entries {
+				assert.Equal(t, filepath.Join(tempDir, info.name), info.path)
+				assert.WithinDuration(t, time.Now(), info.deleted, time.Minute)
+			}
+
+			assert.Equal(t, 5, strings.Count(logBuffer.String(),
+				trashLogPrefix))
+		})
+	}
+}
+
+func TestRunTrashInsideRoot(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 2})
+	defer cleanup()
+
+	trashDir := filepath.Join(tempDir, "trash")
+
+	var buffer, logBuffer bytes.Buffer
+	cfg := config{root: tempDir, ext: []string{".log"}, trash: true,
+		trashDir: trashDir, wLog: &logBuffer}
+
+	// the trashed files are never trashed again
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, run(&buffer, cfg))
+	}
+
+	entries, err := listTrash(trashDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	for _, info := range entries {
+		assert.Equal(t, filepath.Join(tempDir, info.name), info.path)
+	}
+	assert.Equal(t, 2, strings.Count(logBuffer.String(), trashLogPrefix))
+}
+
+func TestTrashFileNameCollision(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	trashDir, cleanupTrash := createTempDir(t, nil)
+	defer cleanupTrash()
+
+	// files with the same name in different directories
+	paths := []string{
+		filepath.Join(tempDir, "a", "file.log"),
+		filepath.Join(tempDir, "b", "file.log"),
+	}
+	for _, path := range paths {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte("dummy"), 0644))
+
+		logger := newLogger(nil, config{wLog: &bytes.Buffer{}}, trashLogPrefix)
+		assert.Nil(t, trashFile(trashDir, path, logger, false))
+	}
+
+	entries, err := listTrash(trashDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	names := map[string]string{}
+	for _, info := range entries {
+		names[info.name] = info.path
+	}
+	assert.Equal(t, 2, len(names))
+	assert.Contains(t, names, "file.log")
+	assert.Contains(t, names, "file.log.2")
+}
+
+func TestEmptyTrash(t *testing.T) {
+	trashDir, cleanup := createTempDir(t, nil)
+	defer cleanup()
+
+	infoDir := filepath.Join(trashDir, "info")
+	filesDir := filepath.Join(trashDir, "files")
+	assert.Nil(t, os.MkdirAll(infoDir, 0700))
+	assert.Nil(t, os.MkdirAll(filesDir, 0700))
+
+	// original path and days since the file was trashed
+	trashed := map[string]int{"/tmp/new.log": 1, "/tmp/old.log": 30}
+	for path, days := range trashed {
+		deleted := time.Now().AddDate(0, 0, -days)
+		name, err := writeTrashInfo(infoDir, path, deleted)
+		assert.Nil(t, err)
+		err = os.WriteFile(filepath.Join(filesDir, name), []byte("dummy"), 0644)
+		assert.Nil(t, err)
+	}
+
+	var buffer bytes.Buffer
+	cfg := config{trashDir: trashDir, olderThan: time.Now().AddDate(0, 0, -7),
+		wLog: &buffer}
+
+	// a dry run doesn't remove anything
+	cfg.dryRun = true
+	assert.Nil(t, emptyTrash(&buffer, cfg))
+	assert.Contains(t, buffer.String(), dryRunPrefix+purgeLogPrefix)
+
+	entries, err := listTrash(trashDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	cfg.dryRun = false
+	buffer.Reset()
+	assert.Nil(t, emptyTrash(&buffer, cfg))
+	assert.Equal(t, 1, strings.Count(buffer.String(), purgeLogPrefix))
+	assert.Contains(t, buffer.String(), "/tmp/old.log")
+
+	entries, err = listTrash(trashDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "/tmp/new.log", entries[0].path)
+
+	files, err := os.ReadDir(filesDir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+}
+
+func TestRestoreFromTrash(t *testing.T) {
+	var (
+		buffer    bytes.Buffer
+		logBuffer bytes.Buffer
+	)
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 5})
+	defer cleanup()
+
+	trashDir, cleanupTrash := createTempDir(t, nil)
+	defer cleanupTrash()
+
+	cfg := config{root: tempDir, ext: []string{".log"}, trash: true,
+		trashDir: trashDir, wLog: &logBuffer}
+	assert.Nil(t, run(&buffer, cfg))
+
+	logFile := filepath.Join(trashDir, "trashed.log")
+	assert.Nil(t, os.WriteFile(logFile, logBuffer.Bytes(), 0644))
+
+	cfg.restore = logFile
+	assert.Nil(t, restoreFiles(&buffer, cfg))
+
+	restored, err := filepath.Glob(filepath.Join(tempDir, "*.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(restored))
+
+	entries, err := listTrash(trashDir)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// followedLink holds the information of the target of a followed symbolic
+// link, while remembering where the link points to.
+type followedLink struct {
+	fs.FileInfo
+	// destination of the link, as stored in the link
+	target string
+}
+
+// linkTarget returns the destination of the symbolic link at 'path', or an
+// empty string if 'path' isn't a link.
+func linkTarget(path string, info fs.FileInfo) string {
+	if fl, ok := info.(followedLink); ok {
+		return fl.target
+	}
+
+	if info.Mode()&fs.ModeSymlink == 0 {
+		return ""
+	}
+
+	target, err := os.Readlink(path)
+	if err != nil {
+		return ""
+	}
+
+	return target
+}
+
+// isLink reports whether the entry is a symbolic link, followed or not
+func isLink(info fs.FileInfo) bool {
+	_, followed := info.(followedLink)
+	return followed || info.Mode()&fs.ModeSymlink != 0
+}
+
+// walker traverses a tree like filepath.Walk does, calling 'fn' for every
+// entry in lexical order. When 'follow' is set, symbolic links are replaced
+// by their targets and links to directories are descended into. A link to a
+// directory that is already being walked would loop forever, so it's reported
+// as an entry but never descended into.
+type walker struct {
+	follow bool
+	fn     filepath.WalkFunc
+	// real paths of the directories being walked
+	active map[string]bool
+}
+
+func walkTree(root string, follow bool, fn filepath.WalkFunc) error {
+	w := &walker{follow: follow, fn: fn, active: map[string]bool{}}
+
+	info, err := w.lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = w.walk(root, info)
+	}
+
+	if err == filepath.SkipDir {
+		return nil
+	}
+
+	return err
+}
+
+// lstat returns the information of 'path', or of its target when following
+// links. Broken links are returned as they are.
+func (w *walker) lstat(path string) (fs.FileInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil || !w.follow || info.Mode()&fs.ModeSymlink == 0 {
+		return info, err
+	}
+
+	targetInfo, err := os.Stat(path)
+	if err != nil {
+		return info, nil
+	}
+
+	target, err := os.Readlink(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return followedLink{FileInfo: targetInfo, target: target}, nil
+}
+
+func (w *walker) walk(path string, info fs.FileInfo) error {
+	if !info.IsDir() {
+		return w.fn(path, info, nil)
+	}
+
+	// only links can cause loops
+	if w.follow {
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return w.fn(path, info, err)
+		}
+
+		if w.active[real] {
+			return w.fn(path, info, nil)
+		}
+
+		w.active[real] = true
+		defer delete(w.active, real)
+	}
+
+	entries, err := os.ReadDir(path)
+	err1 := w.fn(path, info, err)
+	if err != nil || err1 != nil {
+		return err1
+	}
+
+	// the entries are already sorted by name
+	for _, entry := range entries {
+		filename := filepath.Join(path, entry.Name())
+
+		fileInfo, err := w.lstat(filename)
+		if err != nil {
+			if err := w.fn(filename, fileInfo, err); err != nil &&
+				err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+
+		err = w.walk(filename, fileInfo)
+		if err != nil {
+			if !fileInfo.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createLinksDir creates a tree with links to files and directories, a loop
+// and a broken link
+func createLinksDir(t *testing.T) (string, func()) {
+	t.Helper()
+
+	tempDir, cleanup := createTempDir(t, nil)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(tempDir, "a.log"),
+		[]byte("dummy"), 0644))
+	assert.Nil(t, os.Mkdir(filepath.Join(tempDir, "dir"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(tempDir, "dir", "b.log"),
+		[]byte("dummy"), 0644))
+
+	links := map[string]string{
+		"link.log": "a.log",
+		"dirlink":  "dir",
+		"dir/loop": "..",
+		"broken":   "missing",
+	}
+	for link, target := range links {
+		assert.Nil(t, os.Symlink(target, filepath.Join(tempDir, link)))
+	}
+
+	return tempDir, cleanup
+}
+
+func TestRunSymlinks(t *testing.T) {
+	testCases := []struct {
+		testName string
+		symlinks string
+		expected []string
+	}{
+		{testName: "File", symlinks: symlinksFile,
+			expected: []string{"a.log", "broken -> missing", "dir/b.log",
+				"dir/loop -> ..", "dirlink -> dir", "link.log -> a.log"}},
+		{testName: "Default", symlinks: "",
+			expected: []string{"a.log", "broken -> missing", "dir/b.log",
+				"dir/loop -> ..", "dirlink -> dir", "link.log -> a.log"}},
+		{testName: "Skip", symlinks: symlinksSkip,
+			expected: []string{"a.log", "dir/b.log"}},
+		{testName: "Only", symlinks: symlinksOnly,
+			expected: []string{"broken -> missing", "dir/loop -> ..",
+				"dirlink -> dir", "link.log -> a.log"}},
+		{testName: "Follow", symlinks: symlinksFollow,
+			expected: []string{"a.log", "broken -> missing", "dir/b.log",
+				"dirlink/b.log", "link.log -> a.log"}},
+	}
+
+	tempDir, cleanup := createLinksDir(t)
+	defer cleanup()
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var buffer bytes.Buffer
+
+			cfg := config{root: tempDir, symlinks: tc.symlinks, list: true}
+			assert.Nil(t, run(&buffer, cfg))
+
+			expected := ""
+			for _, entry := range tc.expected {
+				expected += filepath.Join(tempDir, entry) + "\n"
+			}
+			assert.Equal(t, expected, buffer.String())
+		})
+	}
+}
+
+func TestRunSymlinksFollowInfo(t *testing.T) {
+	var buffer bytes.Buffer
+
+	tempDir, cleanup := createLinksDir(t)
+	defer cleanup()
+
+	// followed links are reported with the information of their target
+	cfg := config{root: tempDir, symlinks: symlinksFollow,
+		ext: []string{".log"}, list: true, format: formatNDJSON}
+	assert.Nil(t, run(&buffer, cfg))
+
+	result := buffer.String()
+	assert.Contains(t, result, `"size":5,"mode":"-rw-r--r--",`)
+	assert.Contains(t, result, `"link":"a.log"`)
+	assert.NotContains(t, result, "Lrwxrwxrwx")
+	assert.Equal(t, 4, strings.Count(result, `"type":"file"`))
+}
+
+func TestRunSymlinksDelete(t *testing.T) {
+	tempDir, cleanup := createLinksDir(t)
+	defer cleanup()
+
+	// deleting links never touches their targets
+	cfg := config{root: tempDir, symlinks: symlinksOnly, del: true,
+		wLog: &bytes.Buffer{}}
+	assert.Nil(t, run(&bytes.Buffer{}, cfg))
+
+	for _, name := range []string{"link.log", "dirlink", "dir/loop",
+		"broken"} {
+		_, err := os.Lstat(filepath.Join(tempDir, name))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+
+	for _, name := range []string{"a.log", "dir/b.log"} {
+		_, err := os.Stat(filepath.Join(tempDir, name))
+		assert.Nil(t, err, name)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// notifier wakes the watch up when something changes in the directories it
+// watches. Changes are also found by scanning regularly, so a notifier that
+// misses some of them, or never wakes the watch up, only adds latency.
+type notifier interface {
+	// add watches the directory 'dir'
+	add(dir string)
+	// events receives a value when something changed since the last one
+	events() <-chan struct{}
+	close() error
+}
+
+// pollNotifier never wakes the watch up, changes are found by scanning
+type pollNotifier struct{}
+
+func (pollNotifier) add(dir string)          {}
+func (pollNotifier) events() <-chan struct{} { return nil }
+func (pollNotifier) close() error            { return nil }
+
+// fileState is what the watch compares to find the files that changed
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s fileState) equal(other fileState) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+// watchFiles applies the actions to the matched files, then keeps scanning
+// the tree every 'cfg.watchInterval', or as soon as something changes where
+// supported, applying them to the files that appear or change. Files still
+// being written are left alone: a file is only processed once it hasn't been
+// modified for 'cfg.watchInterval'. The watch runs until 'stop' is closed,
+// and errors are reported without stopping it.
+func watchFiles(out io.Writer, cfg config, stop <-chan struct{}) error {
+	if cfg.exec != "" {
+		runner, err := newExecutor(cfg)
+		if err != nil {
+			return err
+		}
+		cfg.runner = runner
+	}
+
+	rep := newReporter(out, cfg)
+	sum := newSummary(cfg.dryRun)
+	logs := newLoggers(out, cfg)
+
+	n, err := newNotifier()
+	if err != nil {
+		n = pollNotifier{}
+	}
+	defer n.close()
+
+	ticker := time.NewTicker(cfg.watchInterval)
+	defer ticker.Stop()
+
+	// state of the files when they were last processed
+	seen := map[string]fileState{}
+	initial := true
+
+	for {
+		// only the initial walk counts towards the files scanned
+		scanSum := sum
+		if !initial {
+			scanSum = nil
+		}
+
+		ready, err := scanChanges(cfg, scanSum, n, seen, initial)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			sum.addError(err)
+		}
+		initial = false
+
+		for _, entry := range ready {
+			action, err := processFile(entry.path, entry.info, out, cfg, logs)
+			if err == nil {
+				r := newRecord(entry.path, entry.info, action)
+				sum.add(r)
+				err = rep.report(r)
+			}
+
+			if err != nil {
+				if isCommandError(err) && cfg.execFail == execFailStop {
+					rep.summarize(*sum)
+					return err
+				}
+				fmt.Fprintln(os.Stderr, err)
+				sum.addError(err)
+			}
+		}
+
+		select {
+		case <-stop:
+			return rep.summarize(*sum)
+		case <-ticker.C:
+		case <-n.events():
+		}
+	}
+}
+
+// scanChanges walks the tree and returns the matched files that are ready
+// to be processed: all of them on the first scan, then the ones that
+// appeared or changed since they were last processed and haven't been
+// modified for a while. The newest files kept by 'cfg.keepLast' are found
+// again, so a file is processed once enough newer ones appeared. The
+// directories holding matched files are watched, and the files that
+// disappeared are forgotten.
+func scanChanges(cfg config, sum *summary, n notifier,
+	seen map[string]fileState, initial bool) ([]fileEntry, error) {
+	n.add(cfg.root)
+
+	if cfg.keepLast > 0 {
+		kept, err := newestFiles(cfg)
+		if err != nil {
+			return nil, err
+		}
+		cfg.kept = kept
+	}
+
+	var ready []fileEntry
+	current := map[string]bool{}
+	now := time.Now()
+
+	err := walkFiles(cfg, sum, func(path string, info fs.FileInfo) error {
+		current[path] = true
+
+		// watch the directories down to the file, to notice new ones
+		for dir := filepath.Dir(path); len(dir) > len(cfg.root); {
+			n.add(dir)
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		if last, ok := seen[path]; ok && last.equal(state) {
+			return nil
+		}
+
+		if initial || now.Sub(state.modTime) >= cfg.watchInterval {
+			seen[path] = state
+			ready = append(ready, fileEntry{path, info})
+		}
+		return nil
+	})
+	if err != nil {
+		// the walk stopped early, the files not found may still exist
+		return ready, err
+	}
+
+	for path := range seen {
+		if !current[path] {
+			delete(seen, path)
+		}
+	}
+
+	return ready, nil
+}
+
+// interrupted returns a channel closed when the program is interrupted
+func interrupted() <-chan struct{} {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		close(done)
+	}()
+
+	return done
+}
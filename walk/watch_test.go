@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syncBuffer is a bytes.Buffer safe to read while the watch writes to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.String()
+}
+
+func TestScanChanges(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+
+	tempDir, cleanup := createTree(t, map[string]string{
+		"a.log":     "alpha",
+		"sub/b.log": "bravo",
+		"c.txt":     "charlie",
+	}, old)
+	defer cleanup()
+
+	cfg := config{root: tempDir, ext: []string{".log"},
+		watchInterval: time.Minute}
+	seen := map[string]fileState{}
+
+	readyPaths := func(initial bool) []string {
+		t.Helper()
+		ready, err := scanChanges(cfg, nil, pollNotifier{}, seen,
+			initial)
+		assert.Nil(t, err)
+
+		paths := []string{}
+		for _, entry := range ready {
+			paths = append(paths, relPath(tempDir, entry.path))
+		}
+		return paths
+	}
+
+	// every matched file is ready on the first scan
+	assert.Equal(t, []string{"a.log", "sub/b.log"}, readyPaths(true))
+
+	// then only the files that changed, once they stopped changing
+	assert.Empty(t, readyPaths(false))
+
+	fresh := filepath.Join(tempDir, "sub", "fresh.log")
+	assert.Nil(t, os.WriteFile(fresh, []byte("delta"), 0644))
+	rotated := filepath.Join(tempDir, "a.log")
+	assert.Nil(t, os.WriteFile(rotated, []byte("alpha, again"), 0644))
+	assert.Nil(t, os.Chtimes(rotated, old, old))
+
+	assert.Equal(t, []string{"a.log"}, readyPaths(false))
+
+	assert.Nil(t, os.Chtimes(fresh, old, old))
+	assert.Equal(t, []string{"sub/fresh.log"}, readyPaths(false))
+
+	// files that disappear are forgotten, and processed if they come back
+	assert.Nil(t, os.Remove(fresh))
+	assert.Empty(t, readyPaths(false))
+	_, ok := seen[fresh]
+	assert.False(t, ok)
+
+	assert.Nil(t, os.WriteFile(fresh, []byte("delta"), 0644))
+	assert.Nil(t, os.Chtimes(fresh, old, old))
+	assert.Equal(t, []string{"sub/fresh.log"}, readyPaths(false))
+}
+
+func TestWatchFiles(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+
+	tempDir, cleanup := createTree(t, map[string]string{
+		"app.log": "alpha",
+	}, old)
+	defer cleanup()
+
+	archiveDir, cleanupArchive := createTempDir(t, nil)
+	defer cleanupArchive()
+
+	var buffer, logBuffer syncBuffer
+	cfg := config{root: tempDir, ext: []string{".log"}, archive: archiveDir,
+		del: true, watchInterval: 20 * time.Millisecond, format: formatNDJSON,
+		wLog: &logBuffer}
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- watchFiles(&buffer, cfg, stop)
+	}()
+
+	// the files already there are processed first
+	assert.Eventually(t, func() bool {
+		return strings.Count(logBuffer.String(), archLogPrefix) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// then the ones that appear, as when a log is rotated
+	rotated := filepath.Join(tempDir, "app.log.1.log")
+	assert.Nil(t, os.WriteFile(rotated, []byte("bravo"), 0644))
+	assert.Nil(t, os.Chtimes(rotated, old, old))
+
+	assert.Eventually(t, func() bool {
+		return strings.Count(logBuffer.String(), archLogPrefix) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(stop)
+	assert.Nil(t, <-done)
+
+	for _, name := range []string{"app.log", "app.log.1.log"} {
+		_, err := os.Stat(filepath.Join(tempDir, name))
+		assert.True(t, os.IsNotExist(err), name)
+		_, err = os.Stat(filepath.Join(archiveDir, name+".gz"))
+		assert.Nil(t, err, name)
+	}
+
+	// the summary is written when the watch stops
+	output := buffer.String()
+	assert.Equal(t, 2, strings.Count(output, `"action":"archive+delete"`))
+	assert.Contains(t, output, `"summary"`)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// actionErrors aggregates the errors returned while processing files
+// concurrently so that a single failure doesn't hide the others.
+type actionErrors []error
+
+func (ae actionErrors) Error() string {
+	msgs := make([]string, len(ae))
+	for i, err := range ae {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// a matched file waiting to be processed, numbered by its walk position
+type job struct {
+	index int
+	path  string
+	info  fs.FileInfo
+}
+
+// the buffered output of a processed file
+type result struct {
+	index  int
+	record fileRecord
+	out    bytes.Buffer
+	log    bytes.Buffer
+	err    error
+}
+
+// runWorkers dispatches the matched files to a pool of 'cfg.workers'
+// goroutines. Each file's output is buffered and written in walk order so the
+// results are the same as when running sequentially, and its record is passed
+// to 'emit' in the same order. The walk counts the files it visits in 'sum'.
+func runWorkers(out io.Writer, cfg config, sum *summary,
+	emit func(r fileRecord) error) error {
+	jobs := make(chan job)
+	results := make(chan *result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results <- processJob(j, cfg)
+			}
+		}()
+	}
+
+	done := make(chan actionErrors)
+	go func() {
+		done <- collectResults(out, cfg.wLog, results, emit)
+	}()
+
+	index := 0
+	walkErr := walkFiles(cfg, sum, func(path string, info fs.FileInfo) error {
+		// the files being processed are completed, but no new ones started
+		if cfg.runner.stopped() {
+			return errStopped
+		}
+
+		jobs <- job{index: index, path: path, info: info}
+		index++
+		return nil
+	})
+
+	close(jobs)
+	wg.Wait()
+	close(results)
+
+	errs := <-done
+	if walkErr != nil && walkErr != errStopped {
+		errs = append(errs, walkErr)
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return errs
+	}
+}
+
+// processJob runs the actions for a single file, capturing its output and
+// log messages instead of writing them directly.
+func processJob(j job, cfg config) *result {
+	r := &result{index: j.index}
+
+	jobCfg := cfg
+	jobCfg.wLog = &r.log
+	action, err := processFile(j.path, j.info, &r.out, jobCfg,
+		newLoggers(&r.out, jobCfg))
+	r.record = newRecord(j.path, j.info, action)
+	r.err = err
+
+	return r
+}
+
+// collectResults writes the results to 'out' and 'wLog' in walk order,
+// holding back the ones that finish early.
+func collectResults(out, wLog io.Writer, results <-chan *result,
+	emit func(r fileRecord) error) actionErrors {
+	var errs actionErrors
+
+	pending := map[int]*result{}
+	next := 0
+
+	for r := range results {
+		pending[r.index] = r
+
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if _, err := r.out.WriteTo(out); err != nil {
+				errs = append(errs, err)
+			}
+			if _, err := r.log.WriteTo(wLog); err != nil {
+				errs = append(errs, err)
+			}
+			if r.err != nil {
+				errs = append(errs, r.err)
+				continue
+			}
+			if err := emit(r.record); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errs
+}
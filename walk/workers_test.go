@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWorkers(t *testing.T) {
+	testCases := []struct {
+		testName string
+		cfg      config
+	}{
+		{testName: "ListAll", cfg: config{list: true}},
+		{testName: "ListExtension", cfg: config{ext: []string{".log"}, list: true}},
+		{testName: "ListNoMatch", cfg: config{ext: []string{".sh"}, list: true}},
+	}
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 50, ".gz": 50})
+	defer cleanup()
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			var expected, result bytes.Buffer
+
+			tc.cfg.root = tempDir
+			assert.Nil(t, run(&expected, tc.cfg))
+
+			// the output must be in walk order regardless of the workers
+			tc.cfg.workers = 8
+			assert.Nil(t, run(&result, tc.cfg))
+
+			assert.Equal(t, expected.String(), result.String())
+		})
+	}
+}
+
+func TestRunWorkersDelete(t *testing.T) {
+	var (
+		buffer    bytes.Buffer
+		logBuffer bytes.Buffer
+	)
+
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 50, ".gz": 50})
+	defer cleanup()
+
+	cfg := config{root: tempDir, ext: []string{".log"}, del: true,
+		workers: 8, wLog: &logBuffer}
+	assert.Nil(t, run(&buffer, cfg))
+
+	remaining, err := filepath.Glob(filepath.Join(tempDir, "*.log"))
+	assert.Nil(t, err)
+	assert.Empty(t, remaining)
+
+	lines := bytes.Split(logBuffer.Bytes(), []byte("\n"))
+	assert.Equal(t, 50, len(lines)-1)
+}
+
+func TestRunWorkersErrors(t *testing.T) {
+	tempDir, cleanup := createTempDir(t, map[string]int{".log": 3})
+	defer cleanup()
+
+	// archiving into a file instead of a directory fails for every file
+	archive := filepath.Join(tempDir, "archive")
+	assert.Nil(t, os.WriteFile(archive, []byte{}, 0644))
+
+	// the errors are collected the same way, whatever the number of workers
+	for _, workers := range []int{1, 2} {
+		cfg := config{root: tempDir, ext: []string{".log"}, archive: archive,
+			workers: workers, wLog: &bytes.Buffer{}}
+		err := run(&bytes.Buffer{}, cfg)
+
+		var errs actionErrors
+		assert.True(t, errors.As(err, &errs))
+		assert.Equal(t, 3, len(errs))
+	}
+}